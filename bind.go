@@ -0,0 +1,293 @@
+package expressgo
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Struct tags naming the request values bound to struct fields
+const (
+	paramTag = "param" // Path parameter, from req.Params
+	queryTag = "query" // Query string parameter
+	formTag  = "form"  // Field of a URL encoded or multipart form body
+)
+
+// maxMultipartMemory is the memory used to parse multipart forms when binding them,
+// the remainder of the files is stored in temporary files
+const maxMultipartMemory = 32 << 20
+
+// Bind decodes the body, query string and path parameters of the request into dst, a
+// pointer to a struct, then checks the validation rules of its fields. JSON bodies are
+// decoded with encoding/json; form, query string and path parameter values are assigned
+// to the fields named by their form, query and param tags. Path parameters take
+// precedence over the query string, which takes precedence over the body.
+// It returns a 400 Error if the request cannot be decoded, a 413 Error if the body was
+// not read by a body parser and is larger than DefaultBodyLimit, and a 422 Error listing
+// the failing fields if validation fails.
+func (req *Request) Bind(dst interface{}) error {
+	if err := bindRequest(req, dst); err != nil {
+		return bindError(err)
+	}
+	return validate(dst)
+}
+
+// BindJSON decodes the JSON body of the request into dst and validates it like Bind
+func (req *Request) BindJSON(dst interface{}) error {
+	if err := bindJSON(req, dst); err != nil {
+		return bindError(err)
+	}
+	return validate(dst)
+}
+
+// BindForm assigns the URL encoded or multipart form body of the request to the fields
+// of dst having a form tag, and validates it like Bind
+func (req *Request) BindForm(dst interface{}) error {
+	if err := bindForm(req, dst); err != nil {
+		return bindError(err)
+	}
+	return validate(dst)
+}
+
+// BindQuery assigns the query string parameters to the fields of dst having a query
+// tag, and validates it like Bind
+func (req *Request) BindQuery(dst interface{}) error {
+	if err := bindStruct(dst, queryTag, req.queryValues()); err != nil {
+		return bindError(err)
+	}
+	return validate(dst)
+}
+
+// BindParams assigns the path parameters to the fields of dst having a param tag, and
+// validates it like Bind
+func (req *Request) BindParams(dst interface{}) error {
+	if err := bindStruct(dst, paramTag, paramValues(req.Params)); err != nil {
+		return bindError(err)
+	}
+	return validate(dst)
+}
+
+// bindError converts a binding error to an Error: Errors, such as the 413 Error of a
+// body too large, are returned as is, other errors as a 400 Error
+func bindError(err error) Error {
+	var e Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return BadRequest(err)
+}
+
+// bindStruct assigns values to the fields of the struct pointed to by dst having the given tag
+func bindStruct(dst interface{}, tag string, values url.Values) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("bind: destination must be a non-nil pointer to a struct")
+	}
+	return bindValues(v.Elem(), tag, values)
+}
+
+// bindRequest decodes the body, query string and path parameters of req into dst, see Bind
+func bindRequest(req *Request, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("bind: destination must be a non-nil pointer")
+	}
+
+	if err := bindBody(req, dst); err != nil {
+		return err
+	}
+
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	if err := bindValues(v, queryTag, req.queryValues()); err != nil {
+		return err
+	}
+	return bindValues(v, paramTag, paramValues(req.Params))
+}
+
+// bindBody decodes the JSON or form body of req into dst
+func bindBody(req *Request, dst interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(req.Request.Header.Get("Content-type"))
+	switch {
+	case isJSONMediaType(mediaType):
+		return bindJSON(req, dst)
+	case mediaType == "application/x-www-form-urlencoded", mediaType == "multipart/form-data":
+		return bindForm(req, dst)
+	}
+	return nil
+}
+
+// bindJSON decodes the JSON body of req into dst. A body not read yet by a body parser
+// is limited to DefaultBodyLimit bytes. When the body is empty, e.g. it was consumed by
+// a handler, req.Json is decoded instead.
+func bindJSON(req *Request, dst interface{}) error {
+	if v := reflect.ValueOf(dst); v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("bind: destination must be a non-nil pointer")
+	}
+	b, err := readBody(req, DefaultBodyLimit)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		if req.Json == nil {
+			return nil
+		}
+		if b, err = json.Marshal(req.Json); err != nil {
+			return err
+		}
+	}
+	return decodeJSON(b, dst, false)
+}
+
+// bindForm assigns the fields of the form body of req to the fields of dst with a form tag
+func bindForm(req *Request, dst interface{}) error {
+	if v := reflect.ValueOf(dst); v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("bind: destination must be a non-nil pointer")
+	}
+	if req.Request.PostForm == nil {
+		var err error
+		if strings.HasPrefix(req.Request.Header.Get("Content-type"), "multipart/") {
+			err = req.Request.ParseMultipartForm(maxMultipartMemory)
+		} else {
+			err = req.Request.ParseForm()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	v := reflect.ValueOf(dst).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return bindValues(v, formTag, req.Request.PostForm)
+}
+
+// isJSONMediaType tells whether mediaType is application/json or a +json type
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func paramValues(params map[string]string) url.Values {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	return values
+}
+
+// bindValues assigns values to the fields of the struct v having the given tag.
+// Fields of embedded structs are bound too.
+func bindValues(v reflect.Value, tag string, values url.Values) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := bindValues(v.Field(i), tag, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		if vals, ok := values[name]; ok && len(vals) > 0 {
+			if err := setFieldValue(v.Field(i), vals); err != nil {
+				return fmt.Errorf("invalid value for %s '%s': %v", tag, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setFieldValue converts vals to the type of the field and assigns it. Slice fields
+// receive all the values, other fields the first one.
+func setFieldValue(fv reflect.Value, vals []string) error {
+	switch {
+	case fv.Kind() == reflect.Ptr:
+		p := reflect.New(fv.Type().Elem())
+		if err := setFieldValue(p.Elem(), vals); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8:
+		s := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setScalar(s.Index(i), val); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setScalar(fv, vals[0])
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setScalar converts s to the type of fv and assigns it. Types implementing
+// encoding.TextUnmarshaler, such as time.Time, are supported.
+func setScalar(fv reflect.Value, s string) error {
+	if fv.CanAddr() {
+		if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		// []byte
+		fv.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
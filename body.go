@@ -0,0 +1,207 @@
+package expressgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DefaultBodyLimit is the default maximum size in bytes of the request bodies read by
+// the body parsers and by Request.Bind
+const DefaultBodyLimit = 100 << 10
+
+// hasBody tells whether the request has a body to parse
+func hasBody(req *Request) bool {
+	return req.Request.Body != nil && req.Request.Body != http.NoBody && req.Request.ContentLength != 0
+}
+
+// readBody reads the whole body of the request, whatever its transfer encoding, and
+// replaces it with a reader on the data read, so it can be read again by the handlers.
+// The data is kept for the following calls, which return it whatever their limit.
+// With a limit > 0, a body larger than limit bytes is rejected with a 413 Error.
+func readBody(req *Request, limit int64) ([]byte, error) {
+	if req.body != nil {
+		return req.body, nil
+	}
+	if !hasBody(req) {
+		return nil, nil
+	}
+	if limit > 0 && req.Request.ContentLength > limit {
+		return nil, bodyTooLarge(limit)
+	}
+
+	var r io.Reader = req.Request.Body
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	b, err := io.ReadAll(r)
+	req.Request.Body.Close()
+	if err != nil {
+		return nil, BadRequest(err)
+	}
+	if limit > 0 && int64(len(b)) > limit {
+		return nil, bodyTooLarge(limit)
+	}
+	req.body = b
+	req.Request.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func bodyTooLarge(limit int64) Error {
+	return NewError(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body larger than %d bytes", limit))
+}
+
+// mediaTypeMatches tells whether the media type of contentType matches one of the comma
+// separated patterns of types, which may contain wildcards, e.g. "application/*+json"
+func mediaTypeMatches(contentType string, types string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range strings.Split(types, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyOptions are the options of the body parsers JSON, NDJSON, Raw and Text:
+//   - Limit: maximum size of the body in bytes. Larger bodies are rejected with a 413 error.
+//     0 means no limit.
+//   - Type: comma separated media types of the bodies to parse, wildcards allowed.
+//   - Strict (JSON): when true, malformed JSON is rejected with a 400 error. Otherwise
+//     req.Json is empty.
+//   - UseNumber (JSON, NDJSON): when true, numbers are decoded as json.Number instead of
+//     float64.
+type bodyOptions struct {
+	Limit     int
+	Type      string
+	Strict    bool
+	UseNumber bool
+}
+
+func (o *bodyOptions) merge(src map[string]interface{}) {
+	setStructFromMap(o, src)
+}
+
+// newBodyOptions returns the options of the body parser name, options overriding defaults
+func newBodyOptions(name string, defaults bodyOptions, p []OptionsMap) bodyOptions {
+	options := defaults
+	switch len(p) {
+	case 0:
+		break
+	case 1:
+		options.merge(p[0])
+		break
+	default:
+		panic("Invalid arguments for " + name + ".")
+	}
+	return options
+}
+
+// Raw is the middleware function generator for reading request bodies as bytes into
+// req.RawBody, e.g. to verify the signature of a webhook. It parses
+// application/octet-stream bodies by default, see bodyOptions for its options.
+func Raw(p ...OptionsMap) func(*Request, *Response, func(...Error)) {
+	options := newBodyOptions("Raw", bodyOptions{Limit: DefaultBodyLimit, Type: "application/octet-stream"}, p)
+	return func(req *Request, resp *Response, next func(...Error)) {
+		if mediaTypeMatches(req.Request.Header.Get("Content-type"), options.Type) {
+			b, err := readBody(req, int64(options.Limit))
+			if err != nil {
+				next(ToError(err))
+				return
+			}
+			req.RawBody = b
+		}
+		next()
+	}
+}
+
+// Text is the middleware function generator for reading request bodies as a string into
+// req.Text. It parses text/plain bodies by default, see bodyOptions for its options.
+func Text(p ...OptionsMap) func(*Request, *Response, func(...Error)) {
+	options := newBodyOptions("Text", bodyOptions{Limit: DefaultBodyLimit, Type: "text/plain"}, p)
+	return func(req *Request, resp *Response, next func(...Error)) {
+		if mediaTypeMatches(req.Request.Header.Get("Content-type"), options.Type) {
+			b, err := readBody(req, int64(options.Limit))
+			if err != nil {
+				next(ToError(err))
+				return
+			}
+			req.Text = string(b)
+		}
+		next()
+	}
+}
+
+// NDJSON is the middleware function generator for newline delimited JSON request
+// bodies. The body is not read in advance: req.JSONStream decodes its values one by
+// one as they are received, and reading beyond Limit, unlimited by default, returns
+// a 413 Error.
+//
+//	for req.JSONStream.More() {
+//		var event Event
+//		if err := req.JSONStream.Decode(&event); err != nil {
+//			next(BadRequest(err))
+//			return
+//		}
+//		...
+//	}
+func NDJSON(p ...OptionsMap) func(*Request, *Response, func(...Error)) {
+	options := newBodyOptions("NDJSON", bodyOptions{
+		Limit: 0,
+		Type:  "application/x-ndjson,application/jsonl,application/x-jsonlines"}, p)
+
+	return func(req *Request, resp *Response, next func(...Error)) {
+		if !mediaTypeMatches(req.Request.Header.Get("Content-type"), options.Type) {
+			next()
+			return
+		}
+		if options.Limit > 0 && req.Request.ContentLength > int64(options.Limit) {
+			next(bodyTooLarge(int64(options.Limit)))
+			return
+		}
+
+		var r io.Reader = http.NoBody
+		if hasBody(req) {
+			r = req.Request.Body
+			if options.Limit > 0 {
+				r = &limitedBodyReader{r: r, remaining: int64(options.Limit), limit: int64(options.Limit)}
+			}
+		}
+		req.JSONStream = json.NewDecoder(r)
+		if options.UseNumber {
+			req.JSONStream.UseNumber()
+		}
+		next()
+	}
+}
+
+// limitedBodyReader reads a body up to a limit, and fails with a 413 Error beyond
+type limitedBodyReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedBodyReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, bodyTooLarge(l.limit)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), bodyTooLarge(l.limit)
+	}
+	return n, err
+}
@@ -6,12 +6,15 @@ import (
 	"path"
 )
 
-// GoViewEngine is the middleware function generator for the GO native view engine base on html/template package
+// GoViewEngine is the middleware function generator for the GO native view engine base on html/template package.
+// Templates can use the urlFor function to build the URL of named routes.
 func GoViewEngine(viewdir string) ViewEngine {
 	vd := viewdir
 	return func(templateFile string, data ViewData, resp *Response) {
 		writer := bytes.NewBufferString("")
-		t, err := template.ParseFiles(path.Join(vd, templateFile))
+		t, err := template.New(path.Base(templateFile)).
+			Funcs(template.FuncMap{"urlFor": resp.App.urlForFunc()}).
+			ParseFiles(path.Join(vd, templateFile))
 		if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,149 @@
+package expressgo
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sourceContextLines is the number of source lines shown before and after the line
+// where a panic occurred
+const sourceContextLines = 5
+
+// devErrorPage sends an HTML error page for developers, with the stack trace of the
+// error, the source lines around the failure, and a dump of the request.
+// It is used by the default error handler when DebugMode is on, except in production mode.
+func devErrorPage(err Error, req *Request, resp *Response) {
+	var b strings.Builder
+	title := fmt.Sprintf("%d %s", err.StatusCode, http.StatusText(err.StatusCode))
+
+	b.WriteString("<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>" + html.EscapeString(title) + "</title>")
+	b.WriteString("<style>body{font-family:sans-serif;margin:2em}pre{background:#f4f4f4;padding:1em;overflow:auto}" +
+		"table{border-collapse:collapse}td{border:1px solid #ddd;padding:2px 8px;vertical-align:top;font-family:monospace}" +
+		".fail{background:#fdd}</style></head><body>")
+	b.WriteString("<h1>" + html.EscapeString(title) + "</h1>")
+	b.WriteString("<p>" + html.EscapeString(req.Method()+" "+req.OriginalURL()) + "</p>")
+	if err.Details != "" {
+		b.WriteString("<h2>" + html.EscapeString(err.Details) + "</h2>")
+	}
+	if err.Cause != nil {
+		b.WriteString("<p>Cause: " + html.EscapeString(err.Cause.Error()) + "</p>")
+	}
+
+	if len(err.Stack) > 0 {
+		if file, line := panicLocation(string(err.Stack)); file != "" {
+			writeSourceLines(&b, file, line)
+		}
+		b.WriteString("<h3>Stack trace</h3><pre>" + html.EscapeString(string(err.Stack)) + "</pre>")
+	}
+
+	fields := map[string]string{}
+	for k, v := range err.Fields {
+		fields[k] = fmt.Sprint(v)
+	}
+	writeTable(&b, "Fields", fields)
+
+	headers := map[string]string{}
+	for k, v := range req.Request.Header {
+		headers[k] = strings.Join(v, ", ")
+	}
+	writeTable(&b, "Headers", headers)
+	writeTable(&b, "Params", req.Params)
+	writeTable(&b, "Query", req.Query)
+	if s := req.Session(); s != nil {
+		sessionStoreLock.Lock()
+		values := make(map[string]string, len(s.Values))
+		for k, v := range s.Values {
+			values[k] = v
+		}
+		sessionStoreLock.Unlock()
+		writeTable(&b, "Session", values)
+	}
+	vars := map[string]string{}
+	for k, v := range req.vars {
+		vars[k] = fmt.Sprintf("%v", v)
+	}
+	writeTable(&b, "Request variables", vars)
+	b.WriteString("</body></html>")
+
+	resp.status.StatusCode = err.StatusCode
+	resp.Send(b.String())
+}
+
+// panicLocation returns the source file and line where the panic reported by stack
+// occurred, i.e. the frame following the call to panic. It returns "" if the stack
+// does not come from a panic.
+func panicLocation(stack string) (string, int) {
+	lines := strings.Split(stack, "\n")
+	for i, l := range lines {
+		if !strings.HasPrefix(l, "panic(") {
+			continue
+		}
+		// The location of the call to panic follows, then the function which panicked
+		// and its location
+		if i+3 >= len(lines) {
+			return "", 0
+		}
+		loc := strings.TrimSpace(lines[i+3])
+		if sp := strings.IndexByte(loc, ' '); sp >= 0 {
+			loc = loc[:sp]
+		}
+		colon := strings.LastIndexByte(loc, ':')
+		if colon < 0 {
+			return "", 0
+		}
+		line, err := strconv.Atoi(loc[colon+1:])
+		if err != nil {
+			return "", 0
+		}
+		return loc[:colon], line
+	}
+	return "", 0
+}
+
+// writeSourceLines writes the lines of file around line, if the file is readable
+func writeSourceLines(b *strings.Builder, file string, line int) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	b.WriteString("<h3>" + html.EscapeString(fmt.Sprintf("%s:%d", file, line)) + "</h3><table>")
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan() && n <= line+sourceContextLines; n++ {
+		if n < line-sourceContextLines {
+			continue
+		}
+		class := ""
+		if n == line {
+			class = " class=\"fail\""
+		}
+		fmt.Fprintf(b, "<tr%s><td>%d</td><td><pre style=\"margin:0;padding:0;background:none\">%s</pre></td></tr>",
+			class, n, html.EscapeString(scanner.Text()))
+	}
+	b.WriteString("</table>")
+}
+
+// writeTable writes the values as an HTML table sorted by key, if there are any
+func writeTable(b *strings.Builder, title string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b.WriteString("<h3>" + html.EscapeString(title) + "</h3><table>")
+	for _, k := range keys {
+		b.WriteString("<tr><td>" + html.EscapeString(k) + "</td><td>" + html.EscapeString(values[k]) + "</td></tr>")
+	}
+	b.WriteString("</table>")
+}
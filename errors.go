@@ -0,0 +1,112 @@
+package expressgo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// Error is the error passed to next() by middleware, and to error handlers.
+// It implements the error interface and can wrap the error that caused it, so that
+// error handlers can use errors.Is and errors.As on it.
+type Error struct {
+	StatusCode int
+	Details    string
+	Cause      error                  // Error that caused this one, if any
+	Stack      []byte                 // Stack trace, set for panics and by WithStack
+	Fields     map[string]interface{} // Structured information about the error
+}
+
+// Error returns the status code and text followed by the details of the error
+func (e Error) Error() string {
+	ret := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	switch {
+	case e.Details != "":
+		ret += ": " + e.Details
+	case e.Cause != nil:
+		ret += ": " + e.Cause.Error()
+	}
+	return ret
+}
+
+// Unwrap returns the error that caused e
+func (e Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithField returns a copy of e with an additional structured field
+func (e Error) WithField(key string, value interface{}) Error {
+	fields := make(map[string]interface{}, len(e.Fields)+1)
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	e.Fields = fields
+	return e
+}
+
+// WithStack returns a copy of e with the stack trace of the caller
+func (e Error) WithStack() Error {
+	e.Stack = debug.Stack()
+	return e
+}
+
+// NewError returns an Error with a status code and details
+func NewError(statusCode int, details string) Error {
+	return Error{StatusCode: statusCode, Details: details}
+}
+
+// WrapError returns an Error with a status code, caused by err
+func WrapError(statusCode int, err error) Error {
+	ret := Error{StatusCode: statusCode, Cause: err}
+	if err != nil {
+		ret.Details = err.Error()
+	}
+	return ret
+}
+
+// ToError converts err to an Error. If err is or wraps an Error, that Error is
+// returned, otherwise err is wrapped in an internal server error.
+func ToError(err error) Error {
+	var e Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return WrapError(http.StatusInternalServerError, err)
+}
+
+// BadRequest returns a 400 Error caused by err
+func BadRequest(err error) Error {
+	return WrapError(http.StatusBadRequest, err)
+}
+
+// Unauthorized returns a 401 Error
+func Unauthorized(msg string) Error {
+	return NewError(http.StatusUnauthorized, msg)
+}
+
+// Forbidden returns a 403 Error
+func Forbidden(msg string) Error {
+	return NewError(http.StatusForbidden, msg)
+}
+
+// NotFound returns a 404 Error
+func NotFound(msg string) Error {
+	return NewError(http.StatusNotFound, msg)
+}
+
+// Conflict returns a 409 Error
+func Conflict(msg string) Error {
+	return NewError(http.StatusConflict, msg)
+}
+
+// UnprocessableEntity returns a 422 Error caused by err
+func UnprocessableEntity(err error) Error {
+	return WrapError(http.StatusUnprocessableEntity, err)
+}
+
+// InternalServerError returns a 500 Error caused by err
+func InternalServerError(err error) Error {
+	return WrapError(http.StatusInternalServerError, err)
+}
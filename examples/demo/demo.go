@@ -15,7 +15,7 @@ func main() {
 	express.DebugMode = true
 	fmt.Println(curdir)
 
-	express.Express().
+	app := express.Express().
 		Set("view-engine", express.GoViewEngine("views")).
 		Use(express.BasicLogger()).
 		Use(express.Session(express.SessionConfig{Timeout: 300, CleanupInterval: 120})).
@@ -33,6 +33,14 @@ func main() {
 			Get("/error", controllers.InternalError).
 			Get("/params/:p1/:p2", controllers.ShowParams).
 			Get("/html5", controllers.Html5ViewPage)).
-		Use(express.Static(curdir+"/public", express.OptionsMap{"DefaultPage": "index.html"})).
-		Listen("localhost:8080")
+		Use("/debug/routes", express.RoutesHandler()).
+		Use(express.Static(curdir+"/public", express.OptionsMap{"DefaultPage": "index.html"}))
+
+	// "demo routes" prints the route table instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "routes" {
+		app.PrintRoutes(os.Stdout)
+		return
+	}
+
+	app.Listen("localhost:8080")
 }
@@ -1,18 +1,18 @@
 package expressgo
 
 import (
+	"context"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
+	"os"
 	"reflect"
+	"runtime/debug"
 	"strings"
+	"time"
 )
 
-type Error struct {
-	StatusCode int
-	Details    string
-}
-
 // ViewData is the data structure for passing data to a view
 type ViewData map[string]string
 type OptionsMap map[string]interface{}
@@ -39,26 +39,46 @@ type ViewEngine func(templateFile string, data ViewData, resp *Response)
 type Middleware struct {
 	Path    string
 	Handler interface{}
+	name    string // Name of the original handler, for handlers converted by Use
 }
 
 // Application the is main application description object
 type Application struct {
-	Name         string
-	middleware   []Middleware
-	vars         map[string]interface{}
-	routes       map[string]Middleware
-	XPoweredBy   string
-	ErrorHandler Middleware
+	Name            string
+	middleware      []Middleware
+	vars            map[string]interface{}
+	routes          map[string]Middleware
+	XPoweredBy      string
+	ErrorHandler    Middleware
+	ShutdownTimeout time.Duration
+	onStart         []func(*Server)
+	onShutdown      []func()
 }
 
 // Express creates a new instance of an application
 func Express() *Application {
 	return &Application{
-		Name:         "Basic application",
-		middleware:   nil,
-		vars:         make(map[string]interface{}),
-		XPoweredBy:   "ExpressGo application server",
-		ErrorHandler: Middleware{Path: "", Handler: defaultErrorPage}}
+		Name:            "Basic application",
+		middleware:      nil,
+		vars:            map[string]interface{}{"env": defaultEnv()},
+		XPoweredBy:      "ExpressGo application server",
+		ErrorHandler:    Middleware{Path: "", Handler: defaultErrorPage},
+		ShutdownTimeout: DefaultShutdownTimeout}
+}
+
+// defaultEnv returns the application environment set by the GO_ENV environment
+// variable, "development" by default
+func defaultEnv() string {
+	if env := os.Getenv("GO_ENV"); env != "" {
+		return env
+	}
+	return "development"
+}
+
+// IsProduction tells whether the application runs in production mode, i.e. its "env"
+// setting is "production". In production mode, error details are not shown to clients.
+func (thisApp *Application) IsProduction() bool {
+	return thisApp.Get("env") == "production"
 }
 
 func (thisApp *Application) Set(key string, value interface{}) *Application {
@@ -74,7 +94,14 @@ func (thisApp *Application) Get(key string) interface{} {
 	}
 }
 
-// Use adds middleware to the application stack
+// Use adds middleware to the application stack.
+// Error handling middleware, func(Error, *Request, *Response, func(...Error)), is only
+// called when a previous middleware passed an error to next() or panicked. It may pass
+// the error on with next(Error), or resume the normal pipeline with next(). Errors not
+// handled by any of them go to ErrorHandler.
+// Besides ExpressGo middleware functions and routers, it accepts net/http handlers
+// (http.Handler, func(http.ResponseWriter, *http.Request)) and net/http middleware
+// (func(http.Handler) http.Handler).
 func (thisApp *Application) Use(p ...interface{}) *Application {
 	if len(p) == 1 {
 		switch p[0].(type) {
@@ -84,6 +111,10 @@ func (thisApp *Application) Use(p ...interface{}) *Application {
 				thisApp.middleware = make([]Middleware, 0)
 			}
 			thisApp.middleware = append(thisApp.middleware, Middleware{Path: "", Handler: mw})
+		case func(Error, *Request, *Response, func(...Error)):
+			thisApp.middleware = append(thisApp.middleware, Middleware{Path: "", Handler: p[0]})
+		case http.Handler, func(http.ResponseWriter, *http.Request), func(http.Handler) http.Handler:
+			thisApp.middleware = append(thisApp.middleware, Middleware{Path: "", Handler: httpHandler(p[0]), name: handlerName(p[0])})
 		default:
 			panic("Use: Invalid type for P1 in Use. Expected func(*Request, *Response) Status")
 
@@ -104,6 +135,11 @@ func (thisApp *Application) Use(p ...interface{}) *Application {
 					thisApp.middleware = make([]Middleware, 0)
 				}
 				thisApp.middleware = append(thisApp.middleware, Middleware{Path: p[0].(string), Handler: mw})
+			case func(Error, *Request, *Response, func(...Error)):
+				thisApp.middleware = append(thisApp.middleware, Middleware{Path: p[0].(string), Handler: p[1]})
+			case http.Handler, func(http.ResponseWriter, *http.Request), func(http.Handler) http.Handler:
+				mw := httpHandler(p[1])
+				thisApp.middleware = append(thisApp.middleware, Middleware{Path: p[0].(string), Handler: mw, name: handlerName(p[1])})
 			default:
 				panic("Use: Invalid type for P2 in Use")
 			}
@@ -114,112 +150,202 @@ func (thisApp *Application) Use(p ...interface{}) *Application {
 	return thisApp
 }
 
-type mainHandler struct {
-	App *Application
-}
-
-// ServeHTTP is the web server main handler function.
-func (hdlr mainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// ServeHTTP is the web server main handler function. It makes the application a
+// standard http.Handler which can be mounted in any net/http server.
+func (thisApp *Application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	req := Request{
-		Request: r,
-		vars:    make(map[string]interface{}),
-		App:     hdlr.App,
-		session: nil,
-		Json:    nil}
+		Request:     r,
+		vars:        make(map[string]interface{}),
+		App:         thisApp,
+		session:     nil,
+		Json:        nil,
+		originalURL: r.URL.RequestURI()}
 	resp := Response{
 		writer:      w,
 		ContentType: "text/html; charset=\"utf-8\"",
 		status:      Error{StatusCode: http.StatusOK, Details: ""},
-		App:         hdlr.App}
+		App:         thisApp}
 
-	resp.SetHeader("X-Powered-By", hdlr.App.XPoweredBy)
+	req.parseQuery()
 
+	resp.SetHeader("X-Powered-By", thisApp.XPoweredBy)
 	defer func() {
-		if e := recover(); e != nil {
-			em := "Unhandled exception"
-			switch e.(type) {
-			case error:
-				em = e.(error).Error()
-			case string:
-				em = e.(string)
-			}
-
-			if resp.status.StatusCode == 200 {
-				resp.status = Error{StatusCode: http.StatusInternalServerError, Details: em}
-			}
-		}
-
-		if resp.status.StatusCode != 200 {
-			hdlr.App.ErrorHandler.Handler.(func(Error, *Request, *Response, func(...Error)))(resp.status, &req, &resp, func(...Error) {})
+		for i := len(req.onDone) - 1; i >= 0; i-- {
+			req.onDone[i]()
 		}
 	}()
 
-	for i := 0; i < len(hdlr.App.middleware); i++ {
-		middlewareIsEndpoint := true
-		middlewareCalled := false
+	httpError := Error{}
+	hasError := false
+	handled := false
+
+	for i := 0; i < len(thisApp.middleware); i++ {
+		mw := thisApp.middleware[i]
+		nextCalled := false
+		var errs []Error
 
 		next := func(p ...Error) {
-			middlewareIsEndpoint = false
-			switch len(p) {
-			case 0:
-				break
-			case 1:
-				if resp.status.StatusCode == 200 {
-					resp.status = p[0]
-				}
-				break
-			default:
+			if len(p) > 1 {
 				panic("next(): extra parameter")
 			}
+			if nextCalled && len(errs) > 0 {
+				// Keep the first error
+				return
+			}
+			nextCalled = true
+			errs = p
 		}
 
-		switch hdlr.App.middleware[i].Handler.(type) {
+		switch h := mw.Handler.(type) {
 		case func(*Request, *Response, func(...Error)):
-			if hdlr.App.middleware[i].Path == "" ||
-				hdlr.App.middleware[i].Path == req.Path() ||
-				strings.HasPrefix(req.Path(), hdlr.App.middleware[i].Path+"/") {
-				middlewareCalled = true
-				hdlr.App.middleware[i].Handler.(func(*Request, *Response, func(...Error)))(&req, &resp, next)
+			if hasError || !mw.matches(req.Path()) {
+				continue
 			}
-			break
+			req.mountPath = mw.Path
+			callHandler(h, &req, &resp, next)
+
+		case func(Error, *Request, *Response, func(...Error)):
+			if !hasError || !mw.matches(req.Path()) {
+				continue
+			}
+			req.mountPath = mw.Path
+			callErrorHandler(h, httpError, &req, &resp, next)
 
 		case *RouterT:
-			req.mountPath = hdlr.App.middleware[i].Path
-			rt := hdlr.App.middleware[i].Handler.(*RouterT)
-			middlewareCalled = true
-			rt.handle(&req, &resp, next)
-			break
+			req.mountPath = mw.Path
+			var pending []Error
+			if hasError {
+				pending = []Error{httpError}
+			}
+			callHandler(func(req *Request, resp *Response, next func(...Error)) {
+				h.handle(req, resp, next, pending...)
+			}, &req, &resp, next)
+
 		default:
-			resp.status = Error{StatusCode: http.StatusNotImplemented, Details: "No handler for type"}
+			next(Error{StatusCode: http.StatusNotImplemented, Details: "No handler for type"})
 		}
-		LogDebug(req.Path() + " " + hdlr.App.middleware[i].Path + " " + fmt.Sprintf("%d", i))
-		if middlewareCalled {
-			if resp.status.StatusCode != 200 || middlewareIsEndpoint {
-				break
-			}
+		LogDebug(req.Path() + " " + mw.Path + " " + fmt.Sprintf("%d", i))
+
+		if !nextCalled {
+			// The middleware handled the request
+			hasError = false
+			handled = true
+			break
+		}
+		hasError = len(errs) > 0
+		if hasError {
+			httpError = errs[0]
+		}
+	}
+
+	if !handled && !req.methodMatched && len(req.allowedMethods) > 0 &&
+		(!hasError || httpError.StatusCode == http.StatusNotFound) {
+		// The path only matches routes for other methods. This takes precedence over
+		// a 404 error from the following middleware, e.g. Static.
+		allowed := strings.Join(appendMethod(req.allowedMethods, "OPTIONS"), ", ")
+		resp.SetHeader("Allow", allowed)
+		if req.Method() == "OPTIONS" {
+			resp.End(allowed)
+			return
+		}
+		hasError = true
+		httpError = Error{StatusCode: http.StatusMethodNotAllowed, Details: req.Method() + " not allowed for " + req.Path()}
+	}
+
+	if hasError {
+		callErrorHandler(thisApp.ErrorHandler.Handler.(func(Error, *Request, *Response, func(...Error))),
+			httpError, &req, &resp, func(...Error) {})
+	}
+}
+
+// matches tells whether the middleware applies to path
+func (mw Middleware) matches(path string) bool {
+	return mw.Path == "" || mw.Path == path || strings.HasPrefix(path, strings.TrimSuffix(mw.Path, "/")+"/")
+}
+
+// callHandler calls a middleware function. A panic in the function is passed to next
+// as an error.
+func callHandler(h func(*Request, *Response, func(...Error)), req *Request, resp *Response, next func(...Error)) {
+	defer func() {
+		if e := recover(); e != nil {
+			next(panicError(e))
+		}
+	}()
+	h(req, resp, next)
+}
+
+// callErrorHandler calls an error handling middleware function. A panic in the function
+// is passed to next as an error.
+func callErrorHandler(h func(Error, *Request, *Response, func(...Error)), err Error, req *Request, resp *Response, next func(...Error)) {
+	defer func() {
+		if e := recover(); e != nil {
+			next(panicError(e))
 		}
+	}()
+	h(err, req, resp, next)
+}
 
+// panicError converts a recovered panic value to an Error, with the stack trace of the panic
+func panicError(e interface{}) Error {
+	var ret Error
+	switch v := e.(type) {
+	case error:
+		ret = ToError(v)
+	case string:
+		ret = Error{StatusCode: http.StatusInternalServerError, Details: v}
+	default:
+		ret = Error{StatusCode: http.StatusInternalServerError, Details: fmt.Sprintf("Unhandled exception: %v", v)}
+	}
+	if ret.Stack == nil {
+		ret.Stack = debug.Stack()
 	}
+	return ret
 }
 
-// Listen starts the web server of the application
+// Listen starts the web server of the application. It blocks until the process
+// receives SIGINT or SIGTERM, then drains in-flight requests before returning.
 func (thisApp *Application) Listen(port string) {
-	h := mainHandler{
-		App: thisApp}
+	if err := thisApp.ListenContext(context.Background(), port); err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+// ListenTLS starts the HTTPS web server of the application, with HTTP/2 enabled.
+// It blocks like Listen.
+func (thisApp *Application) ListenTLS(addr string, certFile string, keyFile string) {
+	thisApp.ListenAll(ListenerConfig{Addr: addr, CertFile: certFile, KeyFile: keyFile})
+}
 
-	if err := http.ListenAndServe(port, h); err != nil {
+// ListenAll serves the application on several listeners at once. It blocks like Listen.
+func (thisApp *Application) ListenAll(listeners ...ListenerConfig) {
+	if err := thisApp.ListenAllContext(context.Background(), listeners...); err != nil {
 		fmt.Println(err.Error())
 	}
 }
 
+// defaultErrorPage responds with application/problem+json to clients accepting JSON,
+// and with an HTML page otherwise. Details of server errors are not shown in production mode.
+// When DebugMode is on, outside production mode, the HTML page is the developer error page.
 func defaultErrorPage(err Error, req *Request, resp *Response, next func(...Error)) {
-	resp.status.StatusCode = err.StatusCode
-	resp.Send(fmt.Sprintf("<h1>%d %s</h1>", err.StatusCode, http.StatusText(err.StatusCode)))
-	resp.Send(resp.status.Details)
+	switch req.Accepts("text/html", "application/problem+json", "application/json") {
+	case "application/problem+json", "application/json":
+		resp.Problem(err, req)
+	default:
+		if DebugMode && !req.App.IsProduction() {
+			devErrorPage(err, req, resp)
+			break
+		}
+		p := NewProblem(err, req)
+		resp.status.StatusCode = err.StatusCode
+		resp.Send(fmt.Sprintf("<h1>%d %s</h1>", p.Status, html.EscapeString(p.Title)))
+		if p.Detail != "" {
+			resp.Send("<p>" + html.EscapeString(p.Detail) + "</p>")
+		}
+	}
 	next()
 }
 
-// DebugMode Sets the debugging messages on/off for the server
+// DebugMode Sets the debugging messages and the developer error page on/off for the server
 var DebugMode = false
 
 // LogDebug writes debug messages if debugmode is on
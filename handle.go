@@ -0,0 +1,46 @@
+package expressgo
+
+import (
+	"context"
+)
+
+type requestContextKey struct{}
+
+// RequestFromContext returns the request handled by a function adapted with Handle,
+// for access to the session, headers or request variables
+func RequestFromContext(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestContextKey{}).(*Request)
+	return req
+}
+
+// Handle adapts a typed function to a middleware function. The request is bound to In
+// and validated with Request.Bind: the JSON or form body, the query string parameters
+// and the path parameters named by the form, query and param struct tags, e.g.
+//
+//	type GetUserInput struct {
+//		ID     int    `param:"id" validate:"min=1"`
+//		Fields string `query:"fields"`
+//	}
+//
+// A request which cannot be bound is rejected with 400 Bad Request, and one failing
+// validation with 422 Unprocessable Entity. The result of fn is sent as JSON. An error
+// returned by fn is passed to next, with its status code if it is or wraps an Error,
+// as 500 Internal Server Error otherwise.
+func Handle[In any, Out any](fn func(context.Context, In) (Out, error)) func(*Request, *Response, func(...Error)) {
+	return func(req *Request, resp *Response, next func(...Error)) {
+		var in In
+		if err := req.Bind(&in); err != nil {
+			next(ToError(err))
+			return
+		}
+
+		ctx := context.WithValue(req.Request.Context(), requestContextKey{}, req)
+		out, err := fn(ctx, in)
+		if err != nil {
+			next(ToError(err))
+			return
+		}
+		resp.writer.Header().Set("Content-Type", "application/json")
+		resp.Json(out)
+	}
+}
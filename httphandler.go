@@ -0,0 +1,125 @@
+package expressgo
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// httpHandler converts a net/http handler or middleware to an ExpressGo middleware function.
+// The request seen by the handler has the path where it is mounted (req.BaseURL())
+// stripped from its URL path.
+func httpHandler(h interface{}) func(*Request, *Response, func(...Error)) {
+	switch h := h.(type) {
+	case func(http.Handler) http.Handler:
+		return httpMiddleware(h)
+	case func(http.ResponseWriter, *http.Request):
+		return httpEndpoint(http.HandlerFunc(h))
+	case http.Handler:
+		return httpEndpoint(h)
+	default:
+		panic("httpHandler: unsupported handler type")
+	}
+}
+
+// httpEndpoint runs a net/http handler. When the handler does not write any response,
+// the request falls through to the next middleware.
+func httpEndpoint(h http.Handler) func(*Request, *Response, func(...Error)) {
+	return func(req *Request, resp *Response, next func(...Error)) {
+		w := &httpResponseWriter{resp: resp}
+		h.ServeHTTP(w, stripMountPath(req.Request, req.BaseURL()))
+		if !w.written {
+			next()
+		}
+	}
+}
+
+type httpNextKey struct{}
+
+type httpNextState struct {
+	called  bool
+	request *http.Request
+}
+
+// httpMiddleware runs a net/http middleware. The handler it wraps calls next(), so the
+// request continues down the ExpressGo pipeline with the request passed to it by the
+// middleware (e.g. with context values added). Response writer wrappers are not used
+// by the rest of the pipeline.
+func httpMiddleware(mw func(http.Handler) http.Handler) func(*Request, *Response, func(...Error)) {
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if st, ok := r.Context().Value(httpNextKey{}).(*httpNextState); ok {
+			st.called = true
+			st.request = r
+		}
+	}))
+
+	return func(req *Request, resp *Response, next func(...Error)) {
+		st := &httpNextState{}
+		r := req.Request.WithContext(context.WithValue(req.Request.Context(), httpNextKey{}, st))
+		h.ServeHTTP(&httpResponseWriter{resp: resp}, stripMountPath(r, req.BaseURL()))
+
+		if st.called {
+			r = st.request.WithContext(st.request.Context())
+			r.URL = req.Request.URL
+			req.Request = r
+			next()
+		}
+	}
+}
+
+// stripMountPath returns a shallow copy of r with the mountPath prefix removed from the URL path
+func stripMountPath(r *http.Request, mountPath string) *http.Request {
+	if mountPath == "" || len(mountPath) > len(r.URL.Path) {
+		return r
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = "/" + strings.TrimLeft(r.URL.Path[len(mountPath):], "/")
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// httpResponseWriter is the http.ResponseWriter given to net/http handlers. It writes
+// to the underlying response and records whether the handler responded.
+type httpResponseWriter struct {
+	resp    *Response
+	written bool
+}
+
+func (w *httpResponseWriter) Header() http.Header {
+	return w.resp.writer.Header()
+}
+
+func (w *httpResponseWriter) WriteHeader(statusCode int) {
+	if w.written {
+		return
+	}
+	w.written = true
+	w.resp.isComplete = true
+	if !w.resp.headersSent {
+		w.resp.headersSent = true
+		w.resp.writer.WriteHeader(statusCode)
+	}
+}
+
+func (w *httpResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.resp.writer.Write(b)
+}
+
+// Flush sends buffered data to the client, for streaming handlers
+func (w *httpResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.resp.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (w *httpResponseWriter) Unwrap() http.ResponseWriter {
+	return w.resp.writer
+}
@@ -1,25 +1,63 @@
 package expressgo
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 )
 
-func JSON() func(req *Request, resp *Response, next func(...Error)) {
+// JSON is the middleware function generator for parsing JSON request bodies, see
+// bodyOptions for its options.
+// A body holding a JSON object is decoded into req.Json. The body remains readable
+// from req.Body, e.g. to decode top-level arrays, or with req.Bind.
+func JSON(p ...OptionsMap) func(req *Request, resp *Response, next func(...Error)) {
+	options := newBodyOptions("JSON", bodyOptions{
+		Limit: DefaultBodyLimit,
+		Type:  "application/json,application/*+json"}, p)
+
 	return func(req *Request, resp *Response, next func(...Error)) {
-		contentType := req.Request.Header.Get("Content-type")
-		if contentType == "application/json" {
-			p := make([]byte, req.ContentLength)
-			_, _ = req.Body.Read(p)
+		if !mediaTypeMatches(req.Request.Header.Get("Content-type"), options.Type) {
+			next()
+			return
+		}
 
-			jsonData := map[string]interface{}{}
+		b, err := readBody(req, int64(options.Limit))
+		if err != nil {
+			next(ToError(err))
+			return
+		}
+
+		req.Json = map[string]interface{}{}
+		if len(bytes.TrimSpace(b)) == 0 {
+			next()
+			return
+		}
 
-			if e := json.Unmarshal(p, &jsonData); e == nil {
-				req.Json = jsonData
-			} else {
-				req.Json = map[string]interface{}{}
+		var v interface{}
+		if err := decodeJSON(b, &v, options.UseNumber); err != nil {
+			if options.Strict {
+				next(BadRequest(err))
+				return
 			}
+		} else if m, ok := v.(map[string]interface{}); ok {
+			req.Json = m
 		}
 		next()
 	}
+}
 
+// decodeJSON decodes the single JSON value b into dst
+func decodeJSON(b []byte, dst interface{}, useNumber bool) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if useNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
 }
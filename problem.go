@@ -0,0 +1,72 @@
+package expressgo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details object, describing an error to API clients
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions are additional members of the problem object, from Error.Fields
+	Extensions map[string]interface{}
+}
+
+// NewProblem returns the problem details object describing err for req. In production
+// mode, the details and fields of server errors (5xx) are not disclosed.
+func NewProblem(err Error, req *Request) Problem {
+	p := Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(err.StatusCode),
+		Status:     err.StatusCode,
+		Detail:     err.Details,
+		Instance:   req.Path(),
+		Extensions: err.Fields}
+
+	if err.StatusCode >= 500 && req.App.IsProduction() {
+		p.Detail = ""
+		p.Extensions = nil
+	}
+	return p
+}
+
+// MarshalJSON encodes the problem with its extension members at the top level
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{}
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// Problem sends err as an application/problem+json response
+func (res *Response) Problem(err Error, req *Request) {
+	b, e := json.Marshal(NewProblem(err, req))
+	if e != nil {
+		b = []byte("{}")
+	}
+	res.status.StatusCode = err.StatusCode
+	res.writer.Header().Set("Content-Type", "application/problem+json")
+	res.End(b)
+}
+
+// ProblemHandler is the error handling middleware generator for APIs: it always
+// responds with application/problem+json.
+func ProblemHandler() func(Error, *Request, *Response, func(...Error)) {
+	return func(err Error, req *Request, resp *Response, next func(...Error)) {
+		resp.Problem(err, req)
+	}
+}
@@ -0,0 +1,251 @@
+package expressgo
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxQueryDepth is the maximum nesting depth of query string keys, further brackets
+// are part of the last key
+const maxQueryDepth = 5
+
+// maxQueryIndex is the largest array index accepted in query string keys such as
+// a[3]. Larger indexes are object keys.
+const maxQueryIndex = 100
+
+// queryPair is a parameter of the query string
+type queryPair struct {
+	key   string
+	value string
+}
+
+// parseQuery parses the query string of the request once, for req.Query and the Query
+// methods. In req.Query, the last value of a repeated parameter is kept.
+func (req *Request) parseQuery() {
+	req.query = parseQueryString(req.Request.URL.RawQuery)
+	req.Query = make(map[string]string, len(req.query))
+	for _, p := range req.query {
+		req.Query[p.key] = p.value
+	}
+}
+
+// parseQueryString splits a query string in its parameters, in order. Keys and values
+// are unescaped, "+" standing for a space. Parts which cannot be unescaped are kept as is.
+func parseQueryString(query string) []queryPair {
+	pairs := []queryPair{}
+	for _, part := range strings.Split(query, "&") {
+		if part == "" {
+			continue
+		}
+		key, value := part, ""
+		if p := strings.IndexByte(part, '='); p >= 0 {
+			key, value = part[:p], part[p+1:]
+		}
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		pairs = append(pairs, queryPair{key: key, value: value})
+	}
+	return pairs
+}
+
+// QueryAll returns all the values of a query string parameter, in order
+func (req *Request) QueryAll(key string) []string {
+	var ret []string
+	for _, p := range req.query {
+		if p.key == key {
+			ret = append(ret, p.value)
+		}
+	}
+	return ret
+}
+
+// queryValues returns the parameters of the query string as url.Values
+func (req *Request) queryValues() url.Values {
+	values := url.Values{}
+	for _, p := range req.query {
+		values.Add(p.key, p.value)
+	}
+	return values
+}
+
+// queryValue returns the last value of a query string parameter
+func (req *Request) queryValue(key string) (string, bool) {
+	for i := len(req.query) - 1; i >= 0; i-- {
+		if req.query[i].key == key {
+			return req.query[i].value, true
+		}
+	}
+	return "", false
+}
+
+// QueryInt returns the value of a query string parameter as an int, or def if the
+// parameter is missing or is not an integer
+func (req *Request) QueryInt(key string, def int) int {
+	if s, ok := req.queryValue(key); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// QueryBool returns the value of a query string parameter as a bool, or def if the
+// parameter is missing or is not a boolean. A parameter without value, as in "?verbose",
+// is true.
+func (req *Request) QueryBool(key string, def bool) bool {
+	s, ok := req.queryValue(key)
+	if !ok {
+		return def
+	}
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "1", "t", "true", "on", "yes":
+		return true
+	case "0", "f", "false", "off", "no":
+		return false
+	}
+	return def
+}
+
+// QueryTime returns the value of a query string parameter as a time, or def if the
+// parameter is missing or is not a time. The value may be in RFC 3339 format, or a
+// date such as 2006-01-02.
+func (req *Request) QueryTime(key string, def time.Time) time.Time {
+	if s, ok := req.queryValue(key); ok {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+				return t
+			}
+		}
+	}
+	return def
+}
+
+// QueryObject returns the query string parsed as nested objects and arrays, like the
+// qs library does:
+//   - a=1&a=2 and a[]=1&a[]=2 give {"a": ["1", "2"]}
+//   - a[0]=1&a[1]=2 gives {"a": ["1", "2"]}
+//   - filter[name]=x&filter[tags][]=y gives {"filter": {"name": "x", "tags": ["y"]}}
+//
+// Values are strings, []interface{} or map[string]interface{}.
+func (req *Request) QueryObject() map[string]interface{} {
+	if req.queryObject == nil {
+		req.queryObject = map[string]interface{}{}
+		for _, p := range req.query {
+			keys := splitQueryKey(p.key)
+			req.queryObject[keys[0]] = setQueryValue(req.queryObject[keys[0]], keys[1:], p.value)
+		}
+		for k, v := range req.queryObject {
+			req.queryObject[k] = compactQueryValue(v)
+		}
+	}
+	return req.queryObject
+}
+
+// splitQueryKey splits a key such as "a[b][]" in "a", "b" and ""
+func splitQueryKey(key string) []string {
+	open := strings.IndexByte(key, '[')
+	if open <= 0 {
+		return []string{key}
+	}
+	keys := []string{key[:open]}
+	rest := key[open:]
+	for len(keys) <= maxQueryDepth && strings.HasPrefix(rest, "[") {
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			break
+		}
+		keys = append(keys, rest[1:end])
+		rest = rest[end+1:]
+	}
+	if rest != "" {
+		// Malformed or too deep: the remainder is a key of its own
+		keys = append(keys, rest)
+	}
+	return keys
+}
+
+// setQueryValue assigns value at the path keys in node, and returns the updated node.
+// A value conflicting with the existing node, e.g. a[b]=1&a=2, is ignored.
+func setQueryValue(node interface{}, keys []string, value string) interface{} {
+	if len(keys) == 0 {
+		switch n := node.(type) {
+		case nil:
+			return value
+		case string:
+			return []interface{}{n, value}
+		case []interface{}:
+			return append(n, value)
+		}
+		return node
+	}
+
+	key := keys[0]
+	if key == "" {
+		switch n := node.(type) {
+		case nil:
+			return []interface{}{setQueryValue(nil, keys[1:], value)}
+		case string:
+			return []interface{}{n, setQueryValue(nil, keys[1:], value)}
+		case []interface{}:
+			return append(n, setQueryValue(nil, keys[1:], value))
+		}
+		return node
+	}
+
+	if index, err := strconv.Atoi(key); err == nil && index >= 0 && index <= maxQueryIndex {
+		switch n := node.(type) {
+		case nil, []interface{}:
+			arr, _ := n.([]interface{})
+			for len(arr) <= index {
+				arr = append(arr, nil)
+			}
+			arr[index] = setQueryValue(arr[index], keys[1:], value)
+			return arr
+		}
+	}
+
+	var m map[string]interface{}
+	switch n := node.(type) {
+	case nil:
+		m = map[string]interface{}{}
+	case map[string]interface{}:
+		m = n
+	case []interface{}:
+		// Array with non index keys: convert it to an object
+		m = map[string]interface{}{}
+		for i, v := range n {
+			if v != nil {
+				m[strconv.Itoa(i)] = v
+			}
+		}
+	default:
+		return node
+	}
+	m[key] = setQueryValue(m[key], keys[1:], value)
+	return m
+}
+
+// compactQueryValue removes the holes left in arrays by missing indexes
+func compactQueryValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case []interface{}:
+		ret := n[:0]
+		for _, e := range n {
+			if e != nil {
+				ret = append(ret, compactQueryValue(e))
+			}
+		}
+		return ret
+	case map[string]interface{}:
+		for k, e := range n {
+			n[k] = compactQueryValue(e)
+		}
+	}
+	return v
+}
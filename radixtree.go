@@ -0,0 +1,327 @@
+package expressgo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type nodeKind uint8
+
+const (
+	staticNode nodeKind = iota
+	paramNode
+	catchAllNode
+)
+
+// node is a node of the compressed radix tree used by RouterT to match request paths.
+// A static node matches a fixed part of the path, a param node matches one path
+// segment, stored under its name, and a catch-all node matches the remainder of the path.
+type node struct {
+	kind       nodeKind
+	path       string         // Static part of the path or parameter name
+	constraint *regexp.Regexp // Pattern the value of a param node must match
+	indices    string         // First byte of each static child
+	children   []*node        // Static children, in the order of indices
+	params     []*node
+	catchAll   *node
+	routes     []int // Indexes of the routes ending at this node
+	mounts     []int // Indexes of the routes matching a path prefix ending at this node
+}
+
+// pathParam is a parameter value extracted from the path
+type pathParam struct {
+	name  string
+	value string
+}
+
+// routeMatch is a route matching a path, with the parameters extracted from the path.
+// For a route matching a path prefix, rest is the remainder of the path.
+type routeMatch struct {
+	index  int
+	params []pathParam
+	rest   string
+}
+
+// patternPart is a static part or a parameter of a route pattern
+type patternPart struct {
+	kind       nodeKind
+	text       string
+	constraint string
+	optional   bool
+}
+
+// splitPattern splits a route pattern in static parts and parameters.
+// Parameters span a whole path segment and take the forms:
+//   - :name matches any segment
+//   - :name(regexp) matches a segment matching regexp, e.g. :id(\d+)
+//   - :name? makes the segment optional, it may be combined with a regexp
+//   - *name or * matches the remainder of the path, it must be the last segment
+//
+// Static parts are matched literally.
+func splitPattern(pattern string) []patternPart {
+	parts := []patternPart{}
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		if (pattern[i] != ':' && pattern[i] != '*') || (i > 0 && pattern[i-1] != '/') {
+			continue
+		}
+		if i > start {
+			parts = append(parts, patternPart{kind: staticNode, text: pattern[start:i]})
+		}
+
+		part := patternPart{kind: paramNode}
+		if pattern[i] == '*' {
+			part.kind = catchAllNode
+		}
+		j := i + 1
+		for j < len(pattern) && isWordChar(pattern[j]) {
+			j++
+		}
+		part.text = pattern[i+1 : j]
+
+		if part.kind == catchAllNode {
+			if j != len(pattern) {
+				panic(fmt.Sprintf("Route: '*' must be the last segment in '%s'", pattern))
+			}
+			if part.text == "" {
+				part.text = "*"
+			}
+		} else {
+			if part.text == "" {
+				panic(fmt.Sprintf("Route: missing parameter name in '%s'", pattern))
+			}
+			if j < len(pattern) && pattern[j] == '(' {
+				end := closingParen(pattern, j)
+				if end < 0 {
+					panic(fmt.Sprintf("Route: unbalanced parenthesis in '%s'", pattern))
+				}
+				part.constraint = pattern[j+1 : end]
+				j = end + 1
+			}
+			if j < len(pattern) && pattern[j] == '?' {
+				part.optional = true
+				j++
+			}
+			if j < len(pattern) && pattern[j] != '/' {
+				panic(fmt.Sprintf("Route: invalid parameter in '%s'", pattern))
+			}
+		}
+
+		parts = append(parts, part)
+		start = j
+		i = j - 1
+	}
+	if start < len(pattern) {
+		parts = append(parts, patternPart{kind: staticNode, text: pattern[start:]})
+	}
+	return parts
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// closingParen returns the position of the parenthesis closing the one at open, or -1
+func closingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expandOptional returns the variants of parts with and without each optional parameter.
+// The slash preceding an omitted parameter is removed along with it.
+func expandOptional(parts []patternPart) [][]patternPart {
+	variants := [][]patternPart{{}}
+	for _, part := range parts {
+		next := [][]patternPart{}
+		for _, v := range variants {
+			with := append(append([]patternPart{}, v...), part)
+			next = append(next, with)
+			if part.optional {
+				without := append([]patternPart{}, v...)
+				if l := len(without) - 1; l >= 0 && without[l].kind == staticNode {
+					without[l].text = strings.TrimSuffix(without[l].text, "/")
+				}
+				next = append(next, without)
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+// insert adds the route with the given index for pattern to the tree. With fold,
+// static parts are stored in lower case for case-insensitive matching. With prefix,
+// the route matches any path starting with pattern followed by "/" or nothing.
+func (n *node) insert(pattern string, index int, fold bool, prefix bool) {
+	if prefix {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	for _, parts := range expandOptional(splitPattern(pattern)) {
+		cur := n
+		for _, part := range parts {
+			switch part.kind {
+			case staticNode:
+				if fold {
+					part.text = strings.ToLower(part.text)
+				}
+				cur = cur.insertStatic(part.text)
+			case paramNode:
+				cur = cur.insertParam(part.text, part.constraint)
+			case catchAllNode:
+				if cur.catchAll == nil {
+					cur.catchAll = &node{kind: catchAllNode, path: part.text}
+				} else if cur.catchAll.path != part.text {
+					panic(fmt.Sprintf("Route: '*%s' conflicts with '*%s' in '%s'", part.text, cur.catchAll.path, pattern))
+				}
+				cur = cur.catchAll
+			}
+		}
+		if prefix {
+			if l := len(cur.mounts); l == 0 || cur.mounts[l-1] != index {
+				cur.mounts = append(cur.mounts, index)
+			}
+			continue
+		}
+		if cur == n {
+			// All the segments were optional and omitted
+			cur = n.insertStatic("/")
+		}
+		if l := len(cur.routes); l == 0 || cur.routes[l-1] != index {
+			cur.routes = append(cur.routes, index)
+		}
+	}
+}
+
+func (n *node) insertStatic(path string) *node {
+	for path != "" {
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			child := &node{kind: staticNode, path: path}
+			n.indices += path[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+
+		child := n.children[i]
+		l := commonPrefixLength(path, child.path)
+		if l < len(child.path) {
+			// Split the child at the end of the common prefix
+			tail := *child
+			tail.path = child.path[l:]
+			*child = node{
+				kind:     staticNode,
+				path:     child.path[:l],
+				indices:  tail.path[:1],
+				children: []*node{&tail}}
+		}
+		n = child
+		path = path[l:]
+	}
+	return n
+}
+
+func (n *node) insertParam(name string, constraint string) *node {
+	for _, p := range n.params {
+		if p.path == name && ((p.constraint == nil && constraint == "") ||
+			(p.constraint != nil && p.constraint.String() == "^(?:"+constraint+")$")) {
+			return p
+		}
+	}
+	child := &node{kind: paramNode, path: name}
+	if constraint != "" {
+		child.constraint = regexp.MustCompile("^(?:" + constraint + ")$")
+	}
+	n.params = append(n.params, child)
+	return child
+}
+
+func commonPrefixLength(a string, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// lookup returns all the routes matching path, in registration order. With fold,
+// static parts of the path are compared case-insensitively.
+func (n *node) lookup(path string, fold bool) []routeMatch {
+	matches := []routeMatch{}
+	n.match(path, fold, nil, &matches)
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].index < matches[j].index })
+
+	// A route with optional parameters may match several times
+	ret := matches[:0]
+	for i, m := range matches {
+		if i == 0 || m.index != matches[i-1].index {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// match collects the routes matching path, which is the part of the request path
+// following the part matched by n.
+func (n *node) match(path string, fold bool, params []pathParam, matches *[]routeMatch) {
+	if len(n.mounts) > 0 && (path == "" || path[0] == '/') {
+		for _, index := range n.mounts {
+			*matches = append(*matches, routeMatch{index: index, params: append([]pathParam(nil), params...), rest: path})
+		}
+	}
+
+	if path == "" {
+		n.record(params, matches)
+	} else {
+		c := path[0]
+		if fold && c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if i := strings.IndexByte(n.indices, c); i >= 0 {
+			child := n.children[i]
+			l := len(child.path)
+			if len(path) >= l && (path[:l] == child.path || (fold && strings.EqualFold(path[:l], child.path))) {
+				child.match(path[l:], fold, params, matches)
+			}
+		}
+
+		if len(n.params) > 0 {
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			if end > 0 {
+				for _, p := range n.params {
+					if p.constraint == nil || p.constraint.MatchString(path[:end]) {
+						p.match(path[end:], fold, append(params, pathParam{name: p.path, value: path[:end]}), matches)
+					}
+				}
+			}
+		}
+	}
+
+	if n.catchAll != nil {
+		n.catchAll.record(append(params, pathParam{name: n.catchAll.path, value: path}), matches)
+	}
+}
+
+// record adds the routes ending at n to matches
+func (n *node) record(params []pathParam, matches *[]routeMatch) {
+	for _, index := range n.routes {
+		*matches = append(*matches, routeMatch{index: index, params: append([]pathParam(nil), params...)})
+	}
+}
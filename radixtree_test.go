@@ -0,0 +1,91 @@
+package expressgo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Benchmarks comparing the radix tree of RouterT with the regexp scan it replaced,
+// for 500 routes similar to a REST API. Run with go test -bench Lookup.
+
+const benchResources = 100
+
+var benchPaths = []string{"/api/res0", "/api/res50/42/items/7", "/api/res99/search", "/not/found"}
+
+// benchPatterns returns 5 routes per resource
+func benchPatterns() []string {
+	ret := []string{}
+	for i := 0; i < benchResources; i++ {
+		r := fmt.Sprintf("/api/res%d", i)
+		ret = append(ret, r, r+"/:id", r+"/:id/edit", r+"/:id/items/:item", r+"/search")
+	}
+	return ret
+}
+
+// regexpRoute is the route matching used by RouterT before the radix tree
+type regexpRoute struct {
+	regexp *regexp.Regexp
+	params []string
+}
+
+func compileRegexpRoute(url string) regexpRoute {
+	rr := regexpRoute{}
+	reParts := []string{}
+	for _, part := range strings.Split(url, "/") {
+		if part != "" {
+			rr.params = append(rr.params, part)
+			if part[0] == ':' {
+				reParts = append(reParts, "(\\w+)")
+			} else {
+				reParts = append(reParts, "("+part+")")
+			}
+		}
+	}
+	rr.regexp = regexp.MustCompile("/" + strings.Join(reParts, "/"))
+	return rr
+}
+
+func matchRegexpRoutes(routes []regexpRoute, path string) map[string]string {
+	for _, rr := range routes {
+		if sm := rr.regexp.FindStringSubmatch(path); sm != nil {
+			params := map[string]string{}
+			for i := range rr.params {
+				if rr.params[i][0] == ':' {
+					params[rr.params[i][1:]] = sm[i+1]
+				}
+			}
+			return params
+		}
+	}
+	return nil
+}
+
+func BenchmarkRegexpScanLookup(b *testing.B) {
+	routes := []regexpRoute{}
+	for _, p := range benchPatterns() {
+		routes = append(routes, compileRegexpRoute(p))
+	}
+	for _, path := range benchPaths {
+		b.Run(path, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matchRegexpRoutes(routes, path)
+			}
+		})
+	}
+}
+
+func BenchmarkRadixTreeLookup(b *testing.B) {
+	tree := &node{kind: staticNode}
+	for i, p := range benchPatterns() {
+		tree.insert(p, i, true, false)
+	}
+	for _, path := range benchPaths {
+		b.Run(path, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tree.lookup(path, true)
+			}
+		})
+	}
+}
@@ -1,18 +1,42 @@
 package expressgo
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
 
 // Request wraps the underlying http.Request object and add a flexible data structure
 // for middelware function to enrich it
 type Request struct {
 	*http.Request
-	session   *HTTPSession
-	Json      map[string]interface{}
-	Params    map[string]string
-	Query     map[string]string
-	vars      map[string]interface{}
-	App       *Application
-	mountPath string
+	session     *HTTPSession
+	Json        map[string]interface{}
+	RawBody     []byte        // Body read by the Raw middleware
+	Text        string        // Body read by the Text middleware
+	JSONStream  *json.Decoder // Decoder of the body values, set by the NDJSON middleware
+	Params      map[string]string
+	Query       map[string]string // Query string parameters, with the last value of repeated ones
+	vars        map[string]interface{}
+	App         *Application
+	mountPath   string
+	originalURL string
+	// Values of the parameters for which RouterT.Param callbacks were called
+	paramsCalled map[string]string
+	// Body of the request, once read by a body parser
+	body []byte
+	// Files received by the Upload middleware, by field name
+	files map[string][]*UploadedFile
+	// Parameters of the query string, in order, and the object parsed from them
+	query       []queryPair
+	queryObject map[string]interface{}
+	// Methods of the routes matching the path but not the method of the request, and
+	// whether a route matched both
+	allowedMethods []string
+	methodMatched  bool
+	// Functions called when the request is complete
+	onDone []func()
 }
 
 func (req *Request) Set(key string, value interface{}) *Request {
@@ -32,16 +56,98 @@ func (req *Request) Session() *HTTPSession {
 	return req.session
 }
 
+// OnDone registers a function called when the request is complete, after the response
+// was sent, e.g. to release resources
+func (req *Request) OnDone(fn func()) *Request {
+	req.onDone = append(req.onDone, fn)
+	return req
+}
+
 // Path returns the path of the current request
 func (req *Request) Path() string {
 	return req.Request.URL.Path
 }
 
+// BaseURL returns the part of the request path where the current router or middleware
+// is mounted, e.g. "/v1/users" for a router mounted with Use("/users", ...) in a
+// router mounted on "/v1"
+func (req *Request) BaseURL() string {
+	return strings.TrimSuffix(req.mountPath, "/")
+}
+
+// OriginalURL returns the path and query of the request as received by the application
+func (req *Request) OriginalURL() string {
+	return req.originalURL
+}
+
 // Method returns the HTTP method of the current request
 func (req *Request) Method() string {
 	return req.Request.Method
 }
 
+// Accepts returns the type among types preferred by the client according to the Accept
+// header of the request, or "" if it accepts none of them. Without Accept header, the
+// first type is returned. The quality of a type is the one of the most specific media
+// range matching it, so "text/html;q=0, */*" refuses text/html. Among types of the same
+// quality, the first one is returned.
+func (req *Request) Accepts(types ...string) string {
+	header := req.Request.Header.Get("Accept")
+	if header == "" {
+		if len(types) > 0 {
+			return types[0]
+		}
+		return ""
+	}
+
+	ranges := strings.Split(header, ",")
+	best := ""
+	bestQ := 0.0
+	for _, t := range types {
+		if q := acceptQuality(strings.ToLower(t), ranges); q > bestQ {
+			best, bestQ = t, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality of the media type t according to the media ranges
+// of an Accept header: the one of the most specific range matching t, or 0
+func acceptQuality(t string, ranges []string) float64 {
+	quality := 0.0
+	bestSpecificity := -1
+	for _, accepted := range ranges {
+		mediaRange, q := parseMediaRange(accepted)
+		specificity := 2
+		switch {
+		case mediaRange == "*/*":
+			specificity = 0
+		case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(t, strings.TrimSuffix(mediaRange, "*")):
+			specificity = 1
+		case mediaRange != t:
+			continue
+		}
+		if specificity > bestSpecificity {
+			quality, bestSpecificity = q, specificity
+		}
+	}
+	return quality
+}
+
+// parseMediaRange returns the media range and quality of an element of an Accept header
+func parseMediaRange(s string) (string, float64) {
+	parts := strings.Split(s, ";")
+	q := 1.0
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = v
+			}
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), q
+}
+
 func (req *Request) UrlValue(key string, def string) string {
 	if v, ok := req.Form[key]; ok {
 		return v[0]
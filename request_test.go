@@ -0,0 +1,37 @@
+package expressgo
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccepts(t *testing.T) {
+	offered := []string{"text/html", "application/problem+json", "application/json"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "text/html"},
+		{"*/*", "text/html"},
+		{"text/html;q=0, */*", "application/problem+json"},
+		{"application/json", "application/json"},
+		{"application/*", "application/problem+json"},
+		{"application/*;q=0.5, text/*;q=0.8", "text/html"},
+		{"text/*;q=0, application/json", "application/json"},
+		{"application/*;q=0, */*;q=0.1", "text/html"},
+		{"text/html;q=0.2, application/json;q=0.9, */*;q=0.1", "application/json"},
+		{"image/png", ""},
+		{"text/html;q=0, application/*;q=0", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		req := &Request{Request: r}
+		if got := req.Accepts(offered...); got != tt.want {
+			t.Errorf("Accept %q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -25,6 +25,12 @@ func (res *Response) Render(templateFile string, data ViewData) *Response {
 	return res
 }
 
+// Status sets the HTTP status code of the response. It must be called before sending data.
+func (res *Response) Status(statusCode int) *Response {
+	res.status.StatusCode = statusCode
+	return res
+}
+
 // End terminates the response No data will be send after that
 func (res *Response) End(s ...interface{}) {
 	for i := range s {
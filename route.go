@@ -0,0 +1,101 @@
+package expressgo
+
+// RouteT is a route created with RouterT.Path. Handlers for several methods share
+// its path pattern:
+//
+//	router.Path("/users/:id").Get(show).Put(auth, update).Delete(auth, remove)
+//
+// Handlers are tried in the order they were added, like routes of a router.
+type RouteT struct {
+	router *RouterT
+	tag    *routeTag
+	url    string
+	verbs  []*routeTag
+}
+
+// Path creates a route for url, to which handlers are added for each method
+func (rt *RouterT) Path(url string) *RouteT {
+	route := &RouteT{router: rt, url: url}
+	tag := routeTag{
+		method: "",
+		url:    url,
+		route:  route}
+	route.tag = &tag
+
+	rt.tree.insert(rt.normalizePath(url), len(rt.routes), !rt.options.CaseSensitive, false)
+	rt.routes = append(rt.routes, &tag)
+	return route
+}
+
+// Method adds handlers for method to the route
+func (route *RouteT) Method(method string, handlers ...func(*Request, *Response, func(...Error))) *RouteT {
+	if len(handlers) == 0 {
+		panic("Route: no handler for " + method + " " + route.url)
+	}
+	route.verbs = append(route.verbs, &routeTag{method: method, url: route.url, handlers: handlers})
+	return route
+}
+
+// All adds handlers for all methods
+func (route *RouteT) All(handlers ...func(*Request, *Response, func(...Error))) *RouteT {
+	return route.Method("ALL", handlers...)
+}
+
+// Get adds handlers for GET method
+func (route *RouteT) Get(handlers ...func(*Request, *Response, func(...Error))) *RouteT {
+	return route.Method("GET", handlers...)
+}
+
+// Post adds handlers for POST method
+func (route *RouteT) Post(handlers ...func(*Request, *Response, func(...Error))) *RouteT {
+	return route.Method("POST", handlers...)
+}
+
+// Put adds handlers for PUT method
+func (route *RouteT) Put(handlers ...func(*Request, *Response, func(...Error))) *RouteT {
+	return route.Method("PUT", handlers...)
+}
+
+// Delete adds handlers for DELETE method
+func (route *RouteT) Delete(handlers ...func(*Request, *Response, func(...Error))) *RouteT {
+	return route.Method("DELETE", handlers...)
+}
+
+// Patch adds handlers for PATCH method
+func (route *RouteT) Patch(handlers ...func(*Request, *Response, func(...Error))) *RouteT {
+	return route.Method("PATCH", handlers...)
+}
+
+// Name names the route, so that its URL can be built with Application.URLFor
+func (route *RouteT) Name(name string) *RouteT {
+	route.tag.name = name
+	return route
+}
+
+// Router returns the router of the route, to continue defining routes
+func (route *RouteT) Router() *RouterT {
+	return route.router
+}
+
+// handle calls the handlers matching the request method in order, as long as they call next()
+func (route *RouteT) handle(req *Request, resp *Response, next func(...Error)) {
+	for _, v := range route.verbs {
+		if !v.matchesMethod(req.Method()) {
+			continue
+		}
+		nextCalled := false
+		var errs []Error
+		runHandlers(v.handlers, req, resp, func(p ...Error) {
+			nextCalled = true
+			errs = p
+		})
+		if !nextCalled {
+			return
+		}
+		if len(errs) > 0 {
+			next(errs[0])
+			return
+		}
+	}
+	next()
+}
@@ -1,22 +1,49 @@
 package expressgo
 
 import (
-	"net/url"
-	"regexp"
+	"fmt"
+	"net/http"
 	"strings"
 )
 
 type routeTag struct {
-	method string
-	url    string
-	regexp *regexp.Regexp
-	params []string
+	method   string
+	url      string
+	handlers []func(*Request, *Response, func(...Error))
+	router   *RouterT // Router mounted with Use
+	route    *RouteT  // Route created with Path
+	name     string   // Name of the route, for URLFor
+	desc     string   // Name of the original handler, for handlers converted by Use
+	mounted  bool     // Added with Use: matches any path starting with url
+	// Error handling middleware added with Use
+	errorHandler func(Error, *Request, *Response, func(...Error))
 }
 
+// RouterT holds a set of routes. Routes are evaluated in registration order: the first
+// route matching the path and method of the request handles it. If its handler calls
+// next(), the next matching route is tried, and when no route is left the request goes
+// on to the middleware following the router. If it calls next(Error), the remaining
+// routes are skipped and the error goes to the error handling middleware added with Use
+// following the route, or is passed on.
+// Middleware and sub-routers added with Use take part in the same ordering.
+// GET routes also handle HEAD requests. When the path only matches routes for other
+// methods, the request goes on to the following middleware. If none handles it, OPTIONS
+// requests get the allowed methods and other requests a 405 error, both with an Allow
+// header.
+// Route patterns must match the whole path. They are stored in a radix tree, so finding
+// the routes matching a path takes a time proportional to the length of the path.
 type RouterT struct {
-	routes map[*routeTag]func(*Request, *Response, func(...Error))
+	routes         []*routeTag
+	tree           *node
+	options        routerOptions
+	paramCallbacks map[string][]func(*Request, *Response, func(...Error), string)
 }
 
+// routerOptions are the options of a router:
+//   - CaseSensitive: when false, "/Page1" matches the route "/page1".
+//   - Strict: when false, a trailing slash is ignored: "/page1/" matches the route "/page1".
+//   - MergeParams: when true, req.Params also contains the parameters set by the parent
+//     of the router. Parameters of the router take precedence.
 type routerOptions struct {
 	CaseSensitive bool
 	MergeParams   bool
@@ -44,146 +71,377 @@ func Router(p ...OptionsMap) *RouterT {
 	}
 
 	rt := new(RouterT)
-	rt.routes = make(map[*routeTag]func(*Request, *Response, func(...Error)))
+	rt.options = options
+	rt.routes = make([]*routeTag, 0)
+	rt.tree = &node{kind: staticNode}
+	rt.paramCallbacks = make(map[string][]func(*Request, *Response, func(...Error), string))
 	return rt
 }
 
-// Route adds a new Route for (method, url) using handler function
-func (rt *RouterT) Route(method string, url string, handler func(*Request, *Response, func(...Error))) *RouterT {
+// Route adds a new Route for (method, url) using handler function.
+// url may contain parameters, available in req.Params: ":name" matches a path segment,
+// ":name(regexp)" a segment matching regexp, ":name?" an optional segment and "*name"
+// (or "*") the remainder of the path.
+// Several handlers may be given. They are called in order, each one calling next() to
+// pass the request to the following one, or next(Error) to stop the chain with an error.
+func (rt *RouterT) Route(method string, url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	if len(handlers) == 0 {
+		panic("Route: no handler for " + method + " " + url)
+	}
 	tag := routeTag{
-		method: method,
-		url:    url,
-		regexp: nil,
-		params: []string{}}
-
-	urlParts := strings.Split(url, "/")
-	reParts := []string{}
-	for level := range urlParts {
-		part := urlParts[level]
-		if part != "" {
-			tag.params = append(tag.params, part)
-			if part[0] == ':' {
-				reParts = append(reParts, "(\\w+)")
-			} else {
-				reParts = append(reParts, "("+part+")")
-			}
+		method:   method,
+		url:      url,
+		handlers: handlers}
+
+	rt.tree.insert(rt.normalizePath(url), len(rt.routes), !rt.options.CaseSensitive, false)
+	rt.routes = append(rt.routes, &tag)
+	return rt
+}
+
+// Name names the last route added to the router, so that its URL can be built with
+// Application.URLFor
+func (rt *RouterT) Name(name string) *RouterT {
+	if len(rt.routes) == 0 {
+		panic("Name: no route to name")
+	}
+	rt.routes[len(rt.routes)-1].name = name
+	return rt
+}
+
+// normalizePath removes the trailing slash of path for non-strict routers
+func (rt *RouterT) normalizePath(path string) string {
+	if !rt.options.Strict && len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// Use adds middleware or a sub-router to the router. The middleware applies to the
+// requests whose path starts with the optional path parameter, which may contain
+// parameters like route paths.
+// It accepts the same handler types as Application.Use, including error handling
+// middleware, which handles the errors of the routes and middleware of the router
+// preceding it, and of its sub-routers.
+func (rt *RouterT) Use(p ...interface{}) *RouterT {
+	path := "/"
+	switch len(p) {
+	case 1:
+		break
+	case 2:
+		if s, ok := p[0].(string); ok {
+			path = s
+		} else {
+			panic("RouterT.Use: Invalid type for P1. Expected string")
 		}
+	default:
+		panic("RouterT.Use: Invalid arguments")
+	}
+
+	tag := routeTag{
+		method:  "",
+		url:     path,
+		mounted: true}
+
+	switch h := p[len(p)-1].(type) {
+	case func(*Request, *Response, func(...Error)):
+		tag.handlers = []func(*Request, *Response, func(...Error)){h}
+	case *RouterT:
+		tag.router = h
+	case func(Error, *Request, *Response, func(...Error)):
+		tag.errorHandler = h
+	case http.Handler, func(http.ResponseWriter, *http.Request), func(http.Handler) http.Handler:
+		tag.handlers = []func(*Request, *Response, func(...Error)){httpHandler(h)}
+		tag.desc = handlerName(h)
+	default:
+		panic("RouterT.Use: Invalid handler type")
 	}
 
-	tag.regexp = regexp.MustCompile("/" + strings.Join(reParts, "/"))
-	rt.routes[&tag] = handler
+	rt.tree.insert(path, len(rt.routes), !rt.options.CaseSensitive, true)
+	rt.routes = append(rt.routes, &tag)
 	return rt
 }
 
-func (rt *RouterT) handle(req *Request, resp *Response, next func(...Error)) {
-	if strings.HasPrefix(req.Path(), req.mountPath) {
-		ru := strings.TrimSuffix(req.mountPath, "/")
-		subPath := strings.TrimPrefix(req.Path(), ru)
-		LogDebug("SubPath:" + subPath)
+// handle dispatches the request to the matching routes and middleware. When errs holds
+// an error, only error handling middleware and sub-routers are called until the error
+// is handled.
+func (rt *RouterT) handle(req *Request, resp *Response, next func(...Error), errs ...Error) {
+	parentParams := req.Params
+	parentMountPath := req.mountPath
+	defer func() {
+		req.Params = parentParams
+		req.mountPath = parentMountPath
+	}()
 
-		httpError := Error{}
-		hasError := false
-		nextCalled := false
+	httpError := Error{}
+	hasError := len(errs) > 0
+	if hasError {
+		httpError = errs[0]
+	}
 
-		lnext := func(p ...Error) {
-			if (len(p)) > 0 {
-				httpError = p[0]
-				hasError = true
-			}
-			nextCalled = true
+	baseURL := req.BaseURL()
+	subPath := strings.TrimPrefix(req.Path(), baseURL)
+	// The router applies to the path where it is mounted and the paths below
+	if strings.HasPrefix(req.Path(), baseURL) && (subPath == "" || subPath[0] == '/') {
+		if subPath == "" {
+			subPath = "/"
 		}
+		subPath = rt.normalizePath(subPath)
+		LogDebug("SubPath:" + subPath)
+
+		methodMatched := false
+		allowed := []string{}
 
-		for k, v := range rt.routes {
-			if sm := k.regexp.FindStringSubmatch(subPath); sm != nil {
-				if k.regexp.MatchString(subPath) && (k.method == req.Method() || k.method == "ALL") {
-					req.Params = map[string]string{}
+		for _, m := range rt.tree.lookup(subPath, !rt.options.CaseSensitive) {
+			k := rt.routes[m.index]
+			if hasError && k.errorHandler == nil && k.router == nil {
+				continue
+			}
+			if !hasError && k.errorHandler != nil {
+				continue
+			}
+			if !k.mounted {
+				for _, method := range k.methods() {
+					allowed = appendMethod(allowed, method)
+				}
+				if !k.matchesMethod(req.Method()) {
+					continue
+				}
+				methodMatched = true
+			}
 
-					for i := range k.params {
-						if k.params[i][0] == ':' {
-							req.Params[k.params[i][1:]] = sm[i+1]
-						}
-					}
+			req.Params = map[string]string{}
+			if rt.options.MergeParams {
+				for k, v := range parentParams {
+					req.Params[k] = v
+				}
+			}
+			for _, p := range m.params {
+				req.Params[p.name] = p.value
+			}
 
-					req.Query = parseQueryString(req.URL.RawQuery)
+			req.mountPath = parentMountPath
+			if k.mounted {
+				req.mountPath = baseURL + subPath[:len(subPath)-len(m.rest)]
+			}
 
-					nextCalled = false
-					v(req, resp, lnext)
-					if !nextCalled || hasError {
-						break
-					}
+			nextCalled := false
+			var nextErrs []Error
+			lnext := func(p ...Error) {
+				if nextCalled && len(nextErrs) > 0 {
+					// Keep the first error
+					return
 				}
+				nextCalled = true
+				nextErrs = p
+			}
+
+			switch {
+			case k.errorHandler != nil:
+				callErrorHandler(k.errorHandler, httpError, req, resp, lnext)
+			case hasError:
+				callHandler(func(req *Request, resp *Response, next func(...Error)) {
+					k.router.handle(req, resp, next, httpError)
+				}, req, resp, lnext)
+			case !rt.processParams(m.params, req, resp, lnext):
+			case k.router != nil:
+				callHandler(func(req *Request, resp *Response, next func(...Error)) {
+					k.router.handle(req, resp, next)
+				}, req, resp, lnext)
+			case k.route != nil:
+				callHandler(k.route.handle, req, resp, lnext)
+			default:
+				callHandler(func(req *Request, resp *Response, next func(...Error)) {
+					runHandlers(k.handlers, req, resp, next)
+				}, req, resp, lnext)
+			}
+
+			if !nextCalled {
+				// The route handled the request
+				return
+			}
+			hasError = len(nextErrs) > 0
+			if hasError {
+				httpError = nextErrs[0]
 			}
 		}
-		if hasError {
-			next(httpError)
-		} else {
-			next()
+
+		if methodMatched {
+			req.methodMatched = true
+		} else if !hasError {
+			// The path matches routes for other methods only, the application answers
+			// with 405 if no other middleware handles the request
+			for _, method := range allowed {
+				req.allowedMethods = appendMethod(req.allowedMethods, method)
+			}
 		}
+	}
+
+	if hasError {
+		next(httpError)
 	} else {
 		next()
 	}
 }
 
-func parseQueryString(query string) map[string]string {
-	ret := map[string]string{}
+// matchesMethod tells whether the route handles requests with method.
+// GET routes also handle HEAD requests.
+func (k *routeTag) matchesMethod(method string) bool {
+	if k.route != nil {
+		for _, v := range k.route.verbs {
+			if v.matchesMethod(method) {
+				return true
+			}
+		}
+		return false
+	}
+	return k.method == method || k.method == "ALL" || (method == "HEAD" && k.method == "GET")
+}
+
+// methods returns the methods handled by the route
+func (k *routeTag) methods() []string {
+	if k.route != nil {
+		ret := []string{}
+		for _, v := range k.route.verbs {
+			ret = append(ret, v.method)
+		}
+		return ret
+	}
+	return []string{k.method}
+}
 
-	urlParts := strings.Split(query, "&")
-	for i := range urlParts {
-		p := strings.IndexByte(urlParts[i], '=')
-		if p >= 0 {
-			if v, e := url.QueryUnescape(urlParts[i][p+1:]); e == nil {
-				ret[urlParts[i][0:p]] = v
-			} else {
-				ret[urlParts[i]] = ""
+// appendMethod adds method, and HEAD for GET, to the list of allowed methods
+func appendMethod(allowed []string, method string) []string {
+	methods := []string{method}
+	switch method {
+	case "ALL":
+		return allowed
+	case "GET":
+		methods = append(methods, "HEAD")
+	}
+	for _, m := range methods {
+		found := false
+		for _, a := range allowed {
+			found = found || a == m
+		}
+		if !found {
+			allowed = append(allowed, m)
+		}
+	}
+	return allowed
+}
+
+// Param adds a callback called before the handlers of the routes of the router having
+// the parameter name in their path, typically to load the object identified by the
+// parameter. The callback receives the value of the parameter. It is called once per
+// request and value, even if several routes match. It must call next() for the request
+// to go on, or next(Error) to fail, e.g. with a 404 error.
+func (rt *RouterT) Param(name string, callback func(*Request, *Response, func(...Error), string)) *RouterT {
+	rt.paramCallbacks[name] = append(rt.paramCallbacks[name], callback)
+	return rt
+}
+
+// processParams calls the Param callbacks for params which were not called yet in this
+// request. It returns false when a callback did not call next(), or called next(Error),
+// in which case next is called with the error.
+func (rt *RouterT) processParams(params []pathParam, req *Request, resp *Response, next func(...Error)) bool {
+	for _, p := range params {
+		callbacks := rt.paramCallbacks[p.name]
+		if len(callbacks) == 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%p:%s", rt, p.name)
+		if v, called := req.paramsCalled[key]; called && v == p.value {
+			continue
+		}
+		if req.paramsCalled == nil {
+			req.paramsCalled = map[string]string{}
+		}
+		req.paramsCalled[key] = p.value
+
+		for _, cb := range callbacks {
+			nextCalled := false
+			var errs []Error
+			cb(req, resp, func(e ...Error) {
+				nextCalled = true
+				errs = e
+			}, p.value)
+			if !nextCalled {
+				return false
+			}
+			if len(errs) > 0 {
+				next(errs[0])
+				return false
 			}
-		} else {
-			ret[urlParts[i]] = ""
 		}
 	}
+	return true
+}
 
-	return ret
+// runHandlers calls a chain of handlers. Each handler must call next() for the following
+// one to be called. next is called when the last handler calls next(), or with the
+// error passed by a handler to next(Error).
+func runHandlers(handlers []func(*Request, *Response, func(...Error)), req *Request, resp *Response, next func(...Error)) {
+	for _, h := range handlers {
+		nextCalled := false
+		var errs []Error
+		h(req, resp, func(p ...Error) {
+			nextCalled = true
+			errs = p
+		})
+		if !nextCalled {
+			return
+		}
+		if len(errs) > 0 {
+			next(errs[0])
+			return
+		}
+	}
+	next()
 }
 
 // All adds new route for all methods
-func (rt *RouterT) All(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Route("ALL", url, handler)
+func (rt *RouterT) All(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Route("ALL", url, handlers...)
 }
 
 // Get adds new route for GET method
-func (rt *RouterT) Get(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Route("GET", url, handler)
+func (rt *RouterT) Get(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Route("GET", url, handlers...)
 }
 
 // Post adds new route for POST method
-func (rt *RouterT) Post(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Route("POST", url, handler)
+func (rt *RouterT) Post(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Route("POST", url, handlers...)
 }
 
 // Put adds new route for PUT method
-func (rt *RouterT) Put(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Route("PUT", url, handler)
+func (rt *RouterT) Put(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Route("PUT", url, handlers...)
 }
 
 // Delete adds new route for DELETE method
-func (rt *RouterT) Delete(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Route("DELETE", url, handler)
+func (rt *RouterT) Delete(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Route("DELETE", url, handlers...)
 }
 
 // Patch adds new route for PATCH method
-func (rt *RouterT) Patch(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Route("PATCH", url, handler)
+func (rt *RouterT) Patch(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Route("PATCH", url, handlers...)
 }
 
 // GetPost adds new route for GET and POST methods
-func (rt *RouterT) GetPost(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Get(url, handler).Post(url, handler)
+func (rt *RouterT) GetPost(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Path(url).Get(handlers...).Post(handlers...).Router()
 }
 
 // RESTFul adds new route for GET,POST, PUT, PATCH, DELETE methods, typcally for restful service
-func (rt *RouterT) RESTFul(url string, handler func(*Request, *Response, func(...Error))) *RouterT {
-	return rt.Get(url, handler).
-		Post(url, handler).
-		Put(url, handler).
-		Patch(url, handler).
-		Delete(url, handler)
+func (rt *RouterT) RESTFul(url string, handlers ...func(*Request, *Response, func(...Error))) *RouterT {
+	return rt.Path(url).
+		Get(handlers...).
+		Post(handlers...).
+		Put(handlers...).
+		Patch(handlers...).
+		Delete(handlers...).
+		Router()
 }
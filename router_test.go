@@ -0,0 +1,160 @@
+package expressgo
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// send returns a handler ending the response with body
+func send(body string) func(*Request, *Response, func(...Error)) {
+	return func(req *Request, resp *Response, next func(...Error)) {
+		resp.End(body)
+	}
+}
+
+func serveRequest(app *Application, method string, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestRouteRegistrationOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(rt *RouterT)
+		want  string
+	}{
+		{"param first", func(rt *RouterT) {
+			rt.Get("/users/:id", send("param"))
+			rt.Get("/users/me", send("static"))
+		}, "param"},
+		{"static first", func(rt *RouterT) {
+			rt.Get("/users/me", send("static"))
+			rt.Get("/users/:id", send("param"))
+		}, "static"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := Router()
+			tt.setup(rt)
+			app := Express().Use("/", rt)
+
+			for i := 0; i < 100; i++ {
+				if got := serveRequest(app, "GET", "/users/me").Body.String(); got != tt.want {
+					t.Fatalf("request %d: got %q, want %q", i, got, tt.want)
+				}
+			}
+			if got := serveRequest(app, "GET", "/users/42").Body.String(); got != "param" {
+				t.Fatalf("GET /users/42: got %q, want %q", got, "param")
+			}
+		})
+	}
+}
+
+func TestNextFallsThrough(t *testing.T) {
+	rt := Router()
+	rt.Get("/users/:id", func(req *Request, resp *Response, next func(...Error)) {
+		req.Set("id", req.Params["id"])
+		next()
+	})
+	rt.Get("/users/me", func(req *Request, resp *Response, next func(...Error)) {
+		next()
+	})
+	rt.Get("/users/:name", func(req *Request, resp *Response, next func(...Error)) {
+		resp.End("third " + req.Get("id").(string))
+	})
+	app := Express().Use("/", rt)
+
+	if got := serveRequest(app, "GET", "/users/me").Body.String(); got != "third me" {
+		t.Fatalf("got %q, want %q", got, "third me")
+	}
+}
+
+func TestNextErrorSkipsRoutes(t *testing.T) {
+	rt := Router()
+	rt.Get("/users/:id", func(req *Request, resp *Response, next func(...Error)) {
+		next(NewError(418, "teapot"))
+	})
+	rt.Get("/users/me", send("skipped route"))
+	app := Express().Use("/", rt)
+	app.Use(send("skipped middleware"))
+
+	w := serveRequest(app, "GET", "/users/me")
+	if w.Code != 418 {
+		t.Fatalf("got status %d, want 418", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "skipped") {
+		t.Fatalf("remaining routes were called: %q", body)
+	}
+}
+
+func TestMethodNotAllowedBeforeStatic(t *testing.T) {
+	rt := Router()
+	rt.Get("/users", send("users"))
+	app := Express().Use("/", rt).Use(Static(t.TempDir()))
+
+	w := serveRequest(app, "POST", "/users")
+	if w.Code != 405 || w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Fatalf("POST: got status %d, Allow %q, want 405 with the allowed methods", w.Code, w.Header().Get("Allow"))
+	}
+	w = serveRequest(app, "OPTIONS", "/users")
+	if w.Code != 200 || w.Body.String() != "GET, HEAD, OPTIONS" {
+		t.Fatalf("OPTIONS: got status %d, body %q", w.Code, w.Body.String())
+	}
+	if w := serveRequest(app, "GET", "/missing"); w.Code != 404 {
+		t.Fatalf("GET /missing: got status %d, want 404", w.Code)
+	}
+}
+
+func TestRouterErrorHandlerScope(t *testing.T) {
+	api := Router()
+	api.Get("/items/:id", func(req *Request, resp *Response, next func(...Error)) {
+		next(NotFound("no item " + req.Params["id"]))
+	})
+	api.Use(func(err Error, req *Request, resp *Response, next func(...Error)) {
+		resp.Status(err.StatusCode).End("api error: " + err.Details)
+	})
+
+	rt := Router()
+	rt.Use("/api", api)
+	rt.Get("/page", func(req *Request, resp *Response, next func(...Error)) {
+		next(NewError(409, "page conflict"))
+	})
+	app := Express().Use("/", rt)
+
+	w := serveRequest(app, "GET", "/api/items/7")
+	if w.Code != 404 || w.Body.String() != "api error: no item 7" {
+		t.Fatalf("GET /api/items/7: got status %d, body %q", w.Code, w.Body.String())
+	}
+	// Errors outside the /api subtree go to the application error handler
+	w = serveRequest(app, "GET", "/page")
+	if w.Code != 409 || strings.Contains(w.Body.String(), "api error") {
+		t.Fatalf("GET /page: got status %d, body %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMountSegmentBoundary(t *testing.T) {
+	api := Router()
+	api.Get("*", func(req *Request, resp *Response, next func(...Error)) {
+		resp.End("api " + req.Params["*"])
+	})
+	app := Express().Use("/api", api).Use(send("fallback"))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api", "api /"},
+		{"/api/", "api /"},
+		{"/api/users/1", "api /users/1"},
+		{"/apiary", "fallback"},
+		{"/api-docs", "fallback"},
+	}
+	for _, tt := range tests {
+		if got := serveRequest(app, "GET", tt.path).Body.String(); got != tt.want {
+			t.Errorf("GET %s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
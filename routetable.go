@@ -0,0 +1,188 @@
+package expressgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes an entry of the application route table
+type RouteInfo struct {
+	Method     string   // HTTP method, ALL for any method, USE for middleware or ERROR for error handling middleware
+	Path       string   // Full path pattern, including the paths where routers are mounted
+	Name       string   // Route name given with Name
+	Handlers   []string // Handler function names
+	Middleware []string // Middleware called before the handlers for this path, in order
+}
+
+// mountedMiddleware is a middleware seen while building the route table
+type mountedMiddleware struct {
+	path string
+	name string
+}
+
+// Routes returns the effective route table of the application: the routes of all the
+// routers and the middleware, in the order they are evaluated.
+func (thisApp *Application) Routes() []RouteInfo {
+	ret := []RouteInfo{}
+	stack := []mountedMiddleware{}
+	for _, mw := range thisApp.middleware {
+		switch h := mw.Handler.(type) {
+		case *RouterT:
+			ret = h.routeTable(mw.Path, stack, ret)
+		case func(Error, *Request, *Response, func(...Error)):
+			ret = append(ret, RouteInfo{
+				Method:     "ERROR",
+				Path:       pathOrRoot(mw.Path),
+				Handlers:   []string{handlerName(h)},
+				Middleware: []string{}})
+		default:
+			name := mw.name
+			if name == "" {
+				name = handlerName(h)
+			}
+			ret = append(ret, RouteInfo{
+				Method:     "USE",
+				Path:       pathOrRoot(mw.Path),
+				Handlers:   []string{name},
+				Middleware: applicableMiddleware(stack, mw.Path)})
+			stack = append(stack, mountedMiddleware{path: mw.Path, name: name})
+		}
+	}
+	return ret
+}
+
+// routeTable appends the routes of rt, mounted on prefix, to table. stack holds the
+// middleware preceding the router.
+func (rt *RouterT) routeTable(prefix string, stack []mountedMiddleware, table []RouteInfo) []RouteInfo {
+	prefix = strings.TrimSuffix(prefix, "/")
+	stack = append([]mountedMiddleware{}, stack...)
+
+	for _, k := range rt.routes {
+		path := prefix + k.url
+		switch {
+		case k.router != nil:
+			table = k.router.routeTable(prefix+strings.TrimSuffix(k.url, "/"), stack, table)
+		case k.errorHandler != nil:
+			table = append(table, RouteInfo{
+				Method:     "ERROR",
+				Path:       pathOrRoot(prefix + strings.TrimSuffix(k.url, "/")),
+				Handlers:   []string{handlerName(k.errorHandler)},
+				Middleware: []string{}})
+		case k.mounted:
+			name := k.desc
+			if name == "" {
+				name = handlerName(k.handlers[0])
+			}
+			path = pathOrRoot(prefix + strings.TrimSuffix(k.url, "/"))
+			table = append(table, RouteInfo{
+				Method:     "USE",
+				Path:       path,
+				Handlers:   []string{name},
+				Middleware: applicableMiddleware(stack, path)})
+			stack = append(stack, mountedMiddleware{path: path, name: name})
+		case k.route != nil:
+			for _, v := range k.route.verbs {
+				table = append(table, RouteInfo{
+					Method:     v.method,
+					Path:       path,
+					Name:       k.name,
+					Handlers:   handlerNames(v.handlers),
+					Middleware: applicableMiddleware(stack, path)})
+			}
+		default:
+			table = append(table, RouteInfo{
+				Method:     k.method,
+				Path:       path,
+				Name:       k.name,
+				Handlers:   handlerNames(k.handlers),
+				Middleware: applicableMiddleware(stack, path)})
+		}
+	}
+	return table
+}
+
+// applicableMiddleware returns the names of the middleware of stack applying to path
+func applicableMiddleware(stack []mountedMiddleware, path string) []string {
+	ret := []string{}
+	for _, mw := range stack {
+		p := strings.TrimSuffix(mw.path, "/")
+		if p == "" || path == p || strings.HasPrefix(path, p+"/") {
+			ret = append(ret, mw.name)
+		}
+	}
+	return ret
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func handlerNames(handlers []func(*Request, *Response, func(...Error))) []string {
+	ret := make([]string, len(handlers))
+	for i, h := range handlers {
+		ret[i] = handlerName(h)
+	}
+	return ret
+}
+
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+// handlerName returns a readable name for a handler: package.Function for functions,
+// or for closures the function returning them, the type name for other handlers
+func handlerName(h interface{}) string {
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return strings.TrimPrefix(fmt.Sprintf("%T", h), "*")
+	}
+	if f := runtime.FuncForPC(v.Pointer()); f != nil {
+		name := f.Name()
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		return closureSuffix.ReplaceAllString(name, "")
+	}
+	return "?"
+}
+
+// PrintRoutes writes the route table of the application to w, one line per route
+func (thisApp *Application) PrintRoutes(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tNAME\tHANDLERS\tMIDDLEWARE")
+	for _, r := range thisApp.Routes() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Method, r.Path, r.Name,
+			strings.Join(r.Handlers, ", "), strings.Join(r.Middleware, " > "))
+	}
+	tw.Flush()
+}
+
+// RoutesHandler is the middleware function generator for a debugging endpoint listing
+// the route table of the application, as text or as JSON when requested by the
+// Accept header.
+func RoutesHandler() func(*Request, *Response, func(...Error)) {
+	return func(req *Request, resp *Response, next func(...Error)) {
+		if strings.Contains(req.Request.Header.Get("Accept"), "application/json") {
+			b, err := json.MarshalIndent(req.App.Routes(), "", "  ")
+			if err != nil {
+				next(InternalServerError(err))
+				return
+			}
+			resp.SetHeader("Content-Type", "application/json")
+			resp.End(b)
+			return
+		}
+
+		sb := &strings.Builder{}
+		req.App.PrintRoutes(sb)
+		resp.SetHeader("Content-Type", "text/plain; charset=utf-8")
+		resp.End(sb.String())
+	}
+}
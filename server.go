@@ -0,0 +1,252 @@
+package expressgo
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time given to in-flight requests to complete when
+// the application server shuts down
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ListenerConfig describes one address the application server listens on.
+type ListenerConfig struct {
+	// Network is "tcp" (default) or "unix". For "unix", Addr is the socket file path.
+	Network string
+	Addr    string
+	// TLS is enabled, with HTTP/2, when both CertFile and KeyFile are set.
+	CertFile string
+	KeyFile  string
+	// RedirectHTTPS makes the listener redirect every request to https on the
+	// port of the given address instead of serving the application.
+	RedirectHTTPS string
+}
+
+// Server is a handle on a running application server. It is returned by Start
+// and allows the caller to stop the server gracefully.
+type Server struct {
+	App         *Application
+	httpServers []*http.Server
+	listeners   []net.Listener
+	failed      chan error
+	done        chan error
+	stopOnce    sync.Once
+	stopErr     error
+}
+
+// OnStart registers a function called when the application server starts listening
+func (thisApp *Application) OnStart(fn func(*Server)) *Application {
+	thisApp.onStart = append(thisApp.onStart, fn)
+	return thisApp
+}
+
+// OnShutdown registers a function called when the application server shuts down,
+// after in-flight requests have been drained
+func (thisApp *Application) OnShutdown(fn func()) *Application {
+	thisApp.onShutdown = append(thisApp.onShutdown, fn)
+	return thisApp
+}
+
+// Start starts the web server of the application in the background and returns
+// immediately. Use the returned Server to shut it down.
+func (thisApp *Application) Start(addr string) (*Server, error) {
+	return thisApp.StartListeners(ListenerConfig{Addr: addr})
+}
+
+// StartListeners starts the web server of the application on all the given
+// listeners in the background and returns immediately.
+func (thisApp *Application) StartListeners(configs ...ListenerConfig) (*Server, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("StartListeners: no listener")
+	}
+
+	srv := &Server{
+		App:    thisApp,
+		failed: make(chan error, len(configs)),
+		done:   make(chan error, 1)}
+
+	for _, cfg := range configs {
+		hs, ln, err := thisApp.newListener(cfg)
+		if err != nil {
+			for _, l := range srv.listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		srv.httpServers = append(srv.httpServers, hs)
+		srv.listeners = append(srv.listeners, ln)
+	}
+
+	for _, fn := range thisApp.onStart {
+		fn(srv)
+	}
+
+	var wg sync.WaitGroup
+	var firstErr error
+	var errLock sync.Mutex
+	for i := range srv.httpServers {
+		wg.Add(1)
+		go func(hs *http.Server, ln net.Listener) {
+			defer wg.Done()
+			var err error
+			if hs.TLSConfig != nil {
+				err = hs.ServeTLS(ln, "", "")
+			} else {
+				err = hs.Serve(ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errLock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errLock.Unlock()
+				srv.failed <- err
+			}
+		}(srv.httpServers[i], srv.listeners[i])
+	}
+
+	go func() {
+		wg.Wait()
+		srv.done <- firstErr
+	}()
+
+	return srv, nil
+}
+
+func (thisApp *Application) newListener(cfg ListenerConfig) (*http.Server, net.Listener, error) {
+	network := cfg.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	hs := &http.Server{Addr: cfg.Addr, Handler: thisApp}
+	if cfg.RedirectHTTPS != "" {
+		hs.Handler = redirectHTTPS(cfg.RedirectHTTPS)
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		hs.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"}}
+	}
+
+	if network == "unix" {
+		// Remove a socket file left over by a previous run
+		if st, err := os.Stat(cfg.Addr); err == nil && st.Mode()&os.ModeSocket != 0 {
+			os.Remove(cfg.Addr)
+		}
+	}
+
+	ln, err := net.Listen(network, cfg.Addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hs, ln, nil
+}
+
+// redirectHTTPS returns a handler redirecting requests to the same URL over https,
+// on the port of httpsAddr
+func redirectHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
+
+// Addr returns the address of the first listener of the server
+func (srv *Server) Addr() string {
+	return srv.listeners[0].Addr().String()
+}
+
+// Addrs returns the addresses of all the listeners of the server
+func (srv *Server) Addrs() []string {
+	ret := make([]string, len(srv.listeners))
+	for i, ln := range srv.listeners {
+		ret[i] = ln.Addr().String()
+	}
+	return ret
+}
+
+// Shutdown stops accepting new connections and waits for in-flight requests
+// to complete, or for ctx to be done, whichever comes first.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.stopOnce.Do(func() {
+		LogDebug("Server shutdown")
+		for _, hs := range srv.httpServers {
+			if err := hs.Shutdown(ctx); err != nil && srv.stopErr == nil {
+				srv.stopErr = err
+			}
+		}
+		stopSessionCleaner()
+		for _, fn := range srv.App.onShutdown {
+			fn()
+		}
+	})
+	return srv.stopErr
+}
+
+// Wait blocks until all the listeners of the server stop and returns the first
+// error that stopped one of them, if any.
+func (srv *Server) Wait() error {
+	err := <-srv.done
+	srv.done <- err
+	return err
+}
+
+// ListenContext starts the web server of the application and blocks until ctx is done
+// or the process receives SIGINT or SIGTERM. In-flight requests are then given
+// ShutdownTimeout to complete.
+func (thisApp *Application) ListenContext(ctx context.Context, addr string) error {
+	return thisApp.ListenAllContext(ctx, ListenerConfig{Addr: addr})
+}
+
+// ListenAllContext is like ListenContext but serves the application on all the given listeners
+func (thisApp *Application) ListenAllContext(ctx context.Context, configs ...ListenerConfig) error {
+	srv, err := thisApp.StartListeners(configs...)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var failure error
+	select {
+	case failure = <-srv.failed:
+	case <-ctx.Done():
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), thisApp.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		return err
+	}
+	if err := srv.Wait(); err != nil {
+		return err
+	}
+	return failure
+}
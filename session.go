@@ -10,7 +10,7 @@ import (
 
 var serverSessions map[string]*HTTPSession
 var sessionIndex int
-var sessionCleanerKeepActive = true
+var sessionCleanerStop chan struct{}
 var sessionStoreLock sync.Mutex
 
 // HTTPSession structure contains the session data and control information
@@ -60,9 +60,21 @@ func (s *HTTPSession) init(sessionID string) {
 	s.Values = make(map[string]string)
 }
 
-func sessionStoreCleaner() {
-	for sessionCleanerKeepActive {
-		time.Sleep(time.Duration(config.CleanupInterval) * time.Second)
+func sessionStoreCleaner(stop chan struct{}) {
+	interval := time.Duration(config.CleanupInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			LogDebug("Session cleaner stopped")
+			return
+		case <-ticker.C:
+		}
 		sessionStoreLock.Lock()
 		LogDebug("Expired session cleanup")
 		for k, v := range serverSessions {
@@ -76,6 +88,17 @@ func sessionStoreCleaner() {
 	}
 }
 
+// stopSessionCleaner stops the expired session cleanup goroutine, if running.
+// It is restarted by the next request using a session.
+func stopSessionCleaner() {
+	sessionStoreLock.Lock()
+	if sessionCleanerStop != nil {
+		close(sessionCleanerStop)
+		sessionCleanerStop = nil
+	}
+	sessionStoreLock.Unlock()
+}
+
 func getHTTPSession(writer http.ResponseWriter, req *http.Request) *HTTPSession {
 	var session *HTTPSession
 	var found bool
@@ -87,7 +110,11 @@ func getHTTPSession(writer http.ResponseWriter, req *http.Request) *HTTPSession
 		LogDebug("Init Session Manager")
 		serverSessions = make(map[string]*HTTPSession)
 		sessionIndex = 12345
-		go sessionStoreCleaner()
+	}
+
+	if sessionCleanerStop == nil {
+		sessionCleanerStop = make(chan struct{})
+		go sessionStoreCleaner(sessionCleanerStop)
 	}
 
 	if c, e := req.Cookie("XprGo-Session-Id"); e == nil {
@@ -0,0 +1,201 @@
+package expressgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// UploadedFile is a file received by the Upload middleware. It is stored in a temporary
+// file, removed when the response is complete.
+type UploadedFile struct {
+	Field       string // Name of the form field
+	Filename    string // Name of the file on the client
+	ContentType string // Media type detected from the file content
+	Size        int64
+	Path        string // Path of the temporary file
+	Header      textproto.MIMEHeader
+}
+
+// Open opens the temporary file for reading
+func (f *UploadedFile) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// uploadOptions are the options of the Upload middleware:
+//   - MaxFileSize: maximum size of a file in bytes. Larger files are rejected with a 413 error.
+//   - MaxFiles: maximum number of files. More files are rejected with a 413 error.
+//   - MaxFieldSize: maximum size of all the non-file fields in bytes.
+//   - AllowedTypes: comma separated media types of the files accepted, wildcards allowed,
+//     e.g. "image/*,application/pdf". Types are detected from the content of the files,
+//     other files are rejected with a 415 error. Empty means all types.
+//   - TempDir: directory of the temporary files, os.TempDir() if empty.
+type uploadOptions struct {
+	MaxFileSize  int
+	MaxFiles     int
+	MaxFieldSize int
+	AllowedTypes string
+	TempDir      string
+}
+
+func (o *uploadOptions) merge(src map[string]interface{}) {
+	setStructFromMap(o, src)
+}
+
+// Upload is the middleware function generator for multipart/form-data bodies. Files are
+// streamed to temporary files, available with req.Files, and other fields are added to
+// req.Form and req.PostForm.
+func Upload(p ...OptionsMap) func(*Request, *Response, func(...Error)) {
+	options := uploadOptions{
+		MaxFileSize:  10 << 20,
+		MaxFiles:     10,
+		MaxFieldSize: 1 << 20,
+		AllowedTypes: "",
+		TempDir:      ""}
+	switch len(p) {
+	case 0:
+		break
+	case 1:
+		options.merge(p[0])
+		break
+	default:
+		panic("Invalid arguments for Upload.")
+	}
+
+	return func(req *Request, resp *Response, next func(...Error)) {
+		if !mediaTypeMatches(req.Request.Header.Get("Content-type"), "multipart/form-data") {
+			next()
+			return
+		}
+		mr, err := req.Request.MultipartReader()
+		if err != nil {
+			next(BadRequest(err))
+			return
+		}
+
+		req.OnDone(func() {
+			for _, files := range req.files {
+				for _, f := range files {
+					os.Remove(f.Path)
+				}
+			}
+		})
+		if err := receiveParts(req, mr, options); err != nil {
+			next(ToError(err))
+			return
+		}
+		next()
+	}
+}
+
+// receiveParts reads the parts of a multipart body
+func receiveParts(req *Request, mr *multipart.Reader, options uploadOptions) error {
+	if req.Request.Form == nil {
+		req.Request.Form = url.Values{}
+	}
+	if req.Request.PostForm == nil {
+		req.Request.PostForm = url.Values{}
+	}
+	if req.files == nil {
+		req.files = map[string][]*UploadedFile{}
+	}
+
+	fieldBytes := 0
+	fileCount := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return BadRequest(err)
+		}
+
+		if part.FileName() == "" {
+			b, err := io.ReadAll(io.LimitReader(part, int64(options.MaxFieldSize-fieldBytes+1)))
+			part.Close()
+			if err != nil {
+				return BadRequest(err)
+			}
+			fieldBytes += len(b)
+			if fieldBytes > options.MaxFieldSize {
+				return NewError(http.StatusRequestEntityTooLarge, fmt.Sprintf("form fields larger than %d bytes", options.MaxFieldSize))
+			}
+			req.Request.Form.Add(part.FormName(), string(b))
+			req.Request.PostForm.Add(part.FormName(), string(b))
+			continue
+		}
+
+		fileCount++
+		if fileCount > options.MaxFiles {
+			part.Close()
+			return NewError(http.StatusRequestEntityTooLarge, fmt.Sprintf("more than %d files", options.MaxFiles))
+		}
+		f, err := receiveFile(part, options)
+		part.Close()
+		if f != nil {
+			req.files[f.Field] = append(req.files[f.Field], f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// receiveFile streams a file part to a temporary file. The file is returned even on
+// error if it was created, so it can be removed.
+func receiveFile(part *multipart.Part, options uploadOptions) (*UploadedFile, error) {
+	// Detect the content type from the first bytes of the file
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, BadRequest(err)
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if options.AllowedTypes != "" && !mediaTypeMatches(contentType, options.AllowedTypes) {
+		return nil, NewError(http.StatusUnsupportedMediaType, fmt.Sprintf("file '%s' has a type not allowed: %s", part.FileName(), contentType))
+	}
+
+	tmp, err := os.CreateTemp(options.TempDir, "upload-*")
+	if err != nil {
+		return nil, InternalServerError(err)
+	}
+	defer tmp.Close()
+	f := &UploadedFile{
+		Field:       part.FormName(),
+		Filename:    filepath.Base(part.FileName()),
+		ContentType: contentType,
+		Path:        tmp.Name(),
+		Header:      part.Header}
+
+	size, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(head), io.LimitReader(part, int64(options.MaxFileSize-n+1))))
+	f.Size = size
+	if err != nil {
+		return f, BadRequest(err)
+	}
+	if size > int64(options.MaxFileSize) {
+		return f, NewError(http.StatusRequestEntityTooLarge, fmt.Sprintf("file '%s' larger than %d bytes", f.Filename, options.MaxFileSize))
+	}
+	return f, nil
+}
+
+// Files returns the files received by the Upload middleware for a form field
+func (req *Request) Files(field string) []*UploadedFile {
+	return req.files[field]
+}
+
+// File returns the first file received by the Upload middleware for a form field, or nil
+func (req *Request) File(field string) *UploadedFile {
+	if files := req.files[field]; len(files) > 0 {
+		return files[0]
+	}
+	return nil
+}
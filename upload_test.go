@@ -0,0 +1,64 @@
+package expressgo
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUploadAfterURLEncoded(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, _ := mw.CreateFormFile("doc", "report.txt")
+	fw.Write([]byte("hello"))
+	mw.Close()
+
+	var path string
+	rt := Router()
+	rt.Post("/up", Upload(OptionsMap{"TempDir": t.TempDir()}), func(req *Request, resp *Response, next func(...Error)) {
+		f := req.File("doc")
+		if f == nil {
+			resp.End("no file")
+			return
+		}
+		path = f.Path
+		r, err := f.Open()
+		if err != nil {
+			next(InternalServerError(err))
+			return
+		}
+		defer r.Close()
+		b, _ := io.ReadAll(r)
+		resp.End(req.Request.PostForm.Get("title") + " " + f.Filename + " " + string(b))
+	})
+	app := Express().Use(URLEncoded()).Use("/", rt)
+
+	r := httptest.NewRequest("POST", "/up", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	if w.Code != 200 || w.Body.String() != "report report.txt hello" {
+		t.Fatalf("got status %d, body %q", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("temporary file %s not removed", path)
+	}
+}
+
+func TestURLEncodedForm(t *testing.T) {
+	app := Express().Use(URLEncoded()).Use(func(req *Request, resp *Response, next func(...Error)) {
+		resp.End(req.Request.PostForm.Get("name"))
+	})
+	r := httptest.NewRequest("POST", "/", strings.NewReader("name=a+b"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	if w.Body.String() != "a b" {
+		t.Fatalf("got %q, want %q", w.Body.String(), "a b")
+	}
+}
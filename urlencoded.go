@@ -1,22 +1,14 @@
 package expressgo
 
-import (
-	"net/http"
-	"strings"
-)
-
-// URLEncoded is the middleware for parsing request body as HTML forms
+// URLEncoded is the middleware for parsing request body as HTML forms.
+// Multipart bodies are not parsed, so that the Upload middleware can stream their files
+// wherever it is mounted. Mount Upload to receive them.
 func URLEncoded() func(*Request, *Response, func(...Error)) {
 	return func(req *Request, resp *Response, next func(...Error)) {
-		contentType := req.Request.Header.Get("Content-type")
-		if contentType == "application/x-www-form-urlencoded" {
+		if mediaTypeMatches(req.Request.Header.Get("Content-type"), "application/x-www-form-urlencoded") {
 			if err := req.Request.ParseForm(); err != nil {
-				next(Error{StatusCode: http.StatusBadRequest, Details: err.Error()})
-			}
-		}
-		if strings.HasPrefix(contentType, "multipart/form-data") {
-			if err := req.Request.ParseMultipartForm(65536); err != nil {
-				next(Error{StatusCode: http.StatusBadRequest, Details: err.Error()})
+				next(BadRequest(err))
+				return
 			}
 		}
 		next()
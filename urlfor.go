@@ -0,0 +1,137 @@
+package expressgo
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// URLFor builds the URL of the route named name, including the paths where its
+// routers are mounted. params gives the values of the route parameters and query
+// the query string parameters.
+func (thisApp *Application) URLFor(name string, params map[string]string, query map[string]string) (string, error) {
+	pattern, found := thisApp.routePattern(name)
+	if !found {
+		return "", fmt.Errorf("URLFor: no route named '%s'", name)
+	}
+
+	ret, err := buildPath(pattern, params)
+	if err != nil {
+		return "", fmt.Errorf("URLFor: route '%s': %s", name, err.Error())
+	}
+
+	if len(query) > 0 {
+		values := url.Values{}
+		for k, v := range query {
+			values.Set(k, v)
+		}
+		ret += "?" + values.Encode()
+	}
+	return ret, nil
+}
+
+// routePattern returns the full path pattern of the route named name
+func (thisApp *Application) routePattern(name string) (string, bool) {
+	for _, mw := range thisApp.middleware {
+		if rt, ok := mw.Handler.(*RouterT); ok {
+			if pattern, found := rt.routePattern(name, mw.Path); found {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// routePattern returns the path pattern of the route named name, for a router mounted on prefix
+func (rt *RouterT) routePattern(name string, prefix string) (string, bool) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	for _, k := range rt.routes {
+		if k.name == name {
+			return prefix + k.url, true
+		}
+		if k.router != nil {
+			if pattern, found := k.router.routePattern(name, prefix+strings.TrimSuffix(k.url, "/")); found {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// buildPath replaces the parameters of pattern with their values in params
+func buildPath(pattern string, params map[string]string) (string, error) {
+	ret := ""
+	for _, part := range splitPattern(pattern) {
+		value := params[part.text]
+		switch part.kind {
+		case staticNode:
+			ret += part.text
+		case paramNode:
+			if value == "" {
+				if !part.optional {
+					return "", fmt.Errorf("missing parameter '%s'", part.text)
+				}
+				ret = strings.TrimSuffix(ret, "/")
+				continue
+			}
+			if part.constraint != "" {
+				if ok, _ := regexp.MatchString("^(?:"+part.constraint+")$", value); !ok {
+					return "", fmt.Errorf("invalid value '%s' for parameter '%s'", value, part.text)
+				}
+			}
+			ret += url.PathEscape(value)
+		case catchAllNode:
+			segments := strings.Split(value, "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			ret += strings.Join(segments, "/")
+		}
+	}
+	if ret == "" {
+		ret = "/"
+	}
+	return ret, nil
+}
+
+// paramNames returns the names of the parameters of pattern
+func paramNames(pattern string) map[string]bool {
+	ret := map[string]bool{}
+	for _, part := range splitPattern(pattern) {
+		if part.kind != staticNode {
+			ret[part.text] = true
+		}
+	}
+	return ret
+}
+
+// urlForFunc returns the urlFor template function of the application. It takes the
+// route name followed by key and value pairs, used as route parameters or, for keys
+// which are not parameters of the route, as query string parameters:
+//
+//	<a href="{{ urlFor "user.show" "id" .ID "tab" "posts" }}">
+func (thisApp *Application) urlForFunc() func(string, ...interface{}) (string, error) {
+	return func(name string, args ...interface{}) (string, error) {
+		if len(args)%2 != 0 {
+			return "", fmt.Errorf("urlFor: odd number of arguments for route '%s'", name)
+		}
+		pattern, found := thisApp.routePattern(name)
+		if !found {
+			return "", fmt.Errorf("urlFor: no route named '%s'", name)
+		}
+
+		names := paramNames(pattern)
+		params := map[string]string{}
+		query := map[string]string{}
+		for i := 0; i < len(args); i += 2 {
+			key := fmt.Sprint(args[i])
+			if names[key] {
+				params[key] = fmt.Sprint(args[i+1])
+			} else {
+				query[key] = fmt.Sprint(args[i+1])
+			}
+		}
+		return thisApp.URLFor(name, params, query)
+	}
+}
@@ -0,0 +1,206 @@
+package expressgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes a field failing a validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// validateTag is the struct tag holding the validation rules of a field, separated by
+// commas, e.g. `validate:"required,min=3,max=20"`. The rules are:
+//   - required: the value is not the zero value
+//   - min=n, max=n: bounds of a number, or of the length of a string, slice or map
+//   - email: the value is an e-mail address
+//   - oneof=a b c: the value is one of the space separated values
+//   - regexp=re: the value matches re; it must be the last rule as re may contain commas
+//
+// Rules other than required are not checked on empty strings and nil pointers.
+const validateTag = "validate"
+
+var validateRegexps sync.Map
+
+// validate checks the validation rules of the fields of the struct pointed to by dst.
+// It returns a 422 Error listing the failing fields in its "errors" field, or nil.
+func validate(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var failures []FieldError
+	validateStruct(v, "", &failures)
+	if len(failures) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(failures))
+	for i, f := range failures {
+		messages[i] = f.Message
+	}
+	return NewError(http.StatusUnprocessableEntity, "validation failed: "+strings.Join(messages, "; ")).WithField("errors", failures)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// validateStruct checks the fields of v, and of its nested structs, appending the
+// failures to failures. Field names are prefixed with prefix.
+func validateStruct(v reflect.Value, prefix string, failures *[]FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		fv := v.Field(i)
+		name := prefix + fieldName(f)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			validateStruct(fv, prefix, failures)
+			continue
+		}
+
+		if rules := f.Tag.Get(validateTag); rules != "" {
+			validateField(fv, name, rules, failures)
+		}
+
+		// Nested structs
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			validateStruct(fv, name+".", failures)
+		}
+	}
+}
+
+// fieldName returns the name of a field as seen by clients: its json, form, query or
+// param name, or its Go name
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", formTag, queryTag, paramTag} {
+		if name := strings.Split(f.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// validateField checks the rules of one field
+func validateField(fv reflect.Value, name string, rules string, failures *[]FieldError) {
+	fail := func(rule string, format string, a ...interface{}) {
+		*failures = append(*failures, FieldError{Field: name, Rule: rule, Message: name + " " + fmt.Sprintf(format, a...)})
+	}
+
+	for rules != "" {
+		var rule string
+		if strings.HasPrefix(rules, "regexp=") {
+			rule, rules = rules, ""
+		} else if i := strings.IndexByte(rules, ','); i >= 0 {
+			rule, rules = rules[:i], rules[i+1:]
+		} else {
+			rule, rules = rules, ""
+		}
+		rule = strings.TrimSpace(rule)
+		ruleName, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			ruleName, arg = rule[:i], rule[i+1:]
+		}
+
+		if ruleName == "required" {
+			if fv.IsZero() {
+				fail(ruleName, "is required")
+				return
+			}
+			continue
+		}
+		if (fv.Kind() == reflect.Ptr && fv.IsNil()) || (fv.Kind() == reflect.String && fv.Len() == 0) {
+			return
+		}
+		value := fv
+		for value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+
+		switch ruleName {
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				panic(fmt.Sprintf("validate: invalid %s rule for %s", ruleName, name))
+			}
+			n, isLength := validationSize(value)
+			switch {
+			case ruleName == "min" && n < bound && isLength:
+				fail(ruleName, "must have at least %s characters or items", arg)
+			case ruleName == "min" && n < bound:
+				fail(ruleName, "must be at least %s", arg)
+			case ruleName == "max" && n > bound && isLength:
+				fail(ruleName, "must have at most %s characters or items", arg)
+			case ruleName == "max" && n > bound:
+				fail(ruleName, "must be at most %s", arg)
+			}
+		case "email":
+			s := fmt.Sprint(value.Interface())
+			if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+				fail(ruleName, "must be an e-mail address")
+			}
+		case "oneof":
+			s := fmt.Sprint(value.Interface())
+			found := false
+			for _, allowed := range strings.Fields(arg) {
+				if s == allowed {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(ruleName, "must be one of %s", strings.Join(strings.Fields(arg), ", "))
+			}
+		case "regexp":
+			re, ok := validateRegexps.Load(arg)
+			if !ok {
+				re, _ = validateRegexps.LoadOrStore(arg, regexp.MustCompile(arg))
+			}
+			if !re.(*regexp.Regexp).MatchString(fmt.Sprint(value.Interface())) {
+				fail(ruleName, "has an invalid format")
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule '%s' for %s", ruleName, name))
+		}
+	}
+}
+
+// validationSize returns the value compared by min and max rules: the value of a
+// number, or the length of a string, slice or map, telling it is a length
+func validationSize(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	panic(fmt.Sprintf("validate: min and max rules do not apply to %s", v.Type()))
+}